@@ -0,0 +1,670 @@
+package querify
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sum adds integers as int64 and all other numbers as float64. Intervals can be summed, too.
+type Sum struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (s Sum) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, s.Filter, s.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := aggregate(record, s.Distinct, s.Expression)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	return sum("sum", values)
+}
+
+func (s Sum) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("sum", s, table)
+}
+
+func (s Sum) SQL() (string, error) {
+	return renderAggregate("sum", s.Distinct, s.OrderBy, s.Filter, s.Expression)
+}
+
+// Avg returns the mean of numbers as float64, or of intervals as Interval.
+type Avg struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (a Avg) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, a.Filter, a.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := aggregate(record, a.Distinct, a.Expression)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	total, err := sum("avg", values)
+	if err != nil {
+		return nil, err
+	}
+
+	return mean(total, len(values)), nil
+}
+
+func (a Avg) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("avg", a, table)
+}
+
+func (a Avg) SQL() (string, error) {
+	return renderAggregate("avg", a.Distinct, a.OrderBy, a.Filter, a.Expression)
+}
+
+// Min returns the smallest value by the rules of the comparison conditions.
+type Min struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (m Min) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, m.Filter, m.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := aggregate(record, m.Distinct, m.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return extremum(values, -1)
+}
+
+func (m Min) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("min", m, table)
+}
+
+func (m Min) SQL() (string, error) {
+	return renderAggregate("min", m.Distinct, m.OrderBy, m.Filter, m.Expression)
+}
+
+// Max returns the largest value by the rules of the comparison conditions.
+type Max struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (m Max) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, m.Filter, m.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := aggregate(record, m.Distinct, m.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return extremum(values, 1)
+}
+
+func (m Max) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("max", m, table)
+}
+
+func (m Max) SQL() (string, error) {
+	return renderAggregate("max", m.Distinct, m.OrderBy, m.Filter, m.Expression)
+}
+
+// StringAgg concatenates the values with the delimiter. NULL values are skipped.
+type StringAgg struct {
+	Distinct   bool
+	Expression Select
+	Delimiter  Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (s StringAgg) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, s.Filter, s.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	_, values, err := s.Expression.Select(record.rows())
+	if err != nil {
+		return nil, err
+	}
+
+	_, delimiters, err := s.Delimiter.Select(record.rows())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		b     strings.Builder
+		found bool
+		seen  = map[string]bool{}
+	)
+
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+
+		t, err := text(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.Distinct {
+			if seen[t] {
+				continue
+			}
+
+			seen[t] = true
+		}
+
+		if found && delimiters[i] != nil {
+			d, err := text(delimiters[i])
+			if err != nil {
+				return nil, err
+			}
+
+			b.WriteString(d)
+		}
+
+		b.WriteString(t)
+
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return b.String(), nil
+}
+
+func (s StringAgg) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("string_agg", s, table)
+}
+
+func (s StringAgg) SQL() (string, error) {
+	return renderAggregate("string_agg", s.Distinct, s.OrderBy, s.Filter, s.Expression, s.Delimiter)
+}
+
+// JsonAgg collects all values including NULLs into a JSON array.
+type JsonAgg struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (j JsonAgg) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, j.Filter, j.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	_, values, err := j.Expression.Select(record.rows())
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	if j.Distinct {
+		if values, err = unique(values); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]interface{}, len(values))
+
+	for i, v := range values {
+		if out[i], err = normalize(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+func (j JsonAgg) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("json_agg", j, table)
+}
+
+func (j JsonAgg) SQL() (string, error) {
+	return renderAggregate("json_agg", j.Distinct, j.OrderBy, j.Filter, j.Expression)
+}
+
+// JsonObjectAgg collects key/value pairs into a JSON object. Keys must not be NULL, the last of equal keys wins.
+type JsonObjectAgg struct {
+	Key     Select
+	Value   Select
+	Filter  Condition
+	OrderBy []OrderBy
+}
+
+func (j JsonObjectAgg) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, j.Filter, j.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	_, keys, err := j.Key.Select(record.rows())
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	_, values, err := j.Value.Select(record.rows())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{}, len(keys))
+
+	for i, k := range keys {
+		if k == nil {
+			return nil, fmt.Errorf("querify: json_object_agg key must not be NULL")
+		}
+
+		key, err := text(k)
+		if err != nil {
+			return nil, err
+		}
+
+		if out[key], err = normalize(values[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+func (j JsonObjectAgg) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("json_object_agg", j, table)
+}
+
+func (j JsonObjectAgg) SQL() (string, error) {
+	return renderAggregate("json_object_agg", false, j.OrderBy, j.Filter, j.Key, j.Value)
+}
+
+// BoolAnd is true if all values are true. NULLs are skipped.
+type BoolAnd struct {
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (b BoolAnd) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, b.Filter, b.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical(record, "bool_and", b.Expression, true)
+}
+
+func (b BoolAnd) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("bool_and", b, table)
+}
+
+func (b BoolAnd) SQL() (string, error) {
+	return renderAggregate("bool_and", false, b.OrderBy, b.Filter, b.Expression)
+}
+
+// BoolOr is true if any value is true. NULLs are skipped.
+type BoolOr struct {
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (b BoolOr) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, b.Filter, b.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical(record, "bool_or", b.Expression, false)
+}
+
+func (b BoolOr) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("bool_or", b, table)
+}
+
+func (b BoolOr) SQL() (string, error) {
+	return renderAggregate("bool_or", false, b.OrderBy, b.Filter, b.Expression)
+}
+
+// Every is the SQL standard name of BoolAnd.
+type Every struct {
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (e Every) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, e.Filter, e.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical(record, "every", e.Expression, true)
+}
+
+func (e Every) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("every", e, table)
+}
+
+func (e Every) SQL() (string, error) {
+	return renderAggregate("every", false, e.OrderBy, e.Filter, e.Expression)
+}
+
+// aggregate returns the values of the group without NULLs. Distinct keeps the first of equal values.
+func aggregate(record SelectedRecord, distinct bool, expression Select) ([]Value, error) {
+	_, values, err := expression.Select(record.rows())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Value, 0, len(values))
+
+	for _, v := range values {
+		if v != nil {
+			out = append(out, v)
+		}
+	}
+
+	if distinct {
+		return unique(out)
+	}
+
+	return out, nil
+}
+
+// unique keeps the first of values with equal JSON.
+func unique(values []Value) ([]Value, error) {
+	out := make([]Value, 0, len(values))
+	seen := map[string]bool{}
+
+	for _, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[string(b)] {
+			seen[string(b)] = true
+			out = append(out, v)
+		}
+	}
+
+	return out, nil
+}
+
+func selectAggregate(name string, variable Variable, table SelectedTable) (string, []Value, error) {
+	out := make([]Value, len(table.Grouped))
+
+	for i, g := range table.Grouped {
+		value, err := variable.Variable(SelectedRecord{Grouped: g, Outer: table.outer, now: table.now})
+		if err != nil {
+			return "", nil, err
+		}
+
+		out[i] = value
+	}
+
+	return name, out, nil
+}
+
+// renderAggregate renders aggregates like 'array_agg(DISTINCT x ORDER BY y) FILTER (WHERE z)'.
+func renderAggregate(
+	name string, distinct bool, orders []OrderBy, filter Condition, args ...interface{},
+) (string, error) {
+	var b strings.Builder
+
+	b.WriteString(name + "(")
+
+	if distinct {
+		b.WriteString("DISTINCT ")
+	}
+
+	list, err := renderList(args)
+	if err != nil {
+		return "", err
+	}
+
+	b.WriteString(list)
+
+	if len(orders) > 0 {
+		values := make([]interface{}, len(orders))
+
+		for i, o := range orders {
+			values[i] = o
+		}
+
+		list, err = renderList(values)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(" ORDER BY " + list)
+	}
+
+	b.WriteString(")")
+
+	if filter != nil {
+		cond, err := render(filter)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(" FILTER (WHERE " + cond + ")")
+	}
+
+	return b.String(), nil
+}
+
+// within returns the record with the rows of its group, that pass the filter, sorted by orderBy.
+func within(record SelectedRecord, filter Condition, orderBy []OrderBy) (SelectedRecord, error) {
+	if filter == nil && len(orderBy) == 0 {
+		return record, nil
+	}
+
+	group := record.Grouped
+	records := make([]SelectedRecord, 0, len(group.Data))
+
+	for i, row := range group.Data {
+		r := SelectedRecord{Source: Record{Columns: group.Columns, Values: row}, Outer: record.Outer, now: record.now}
+
+		if i < len(group.groups) {
+			r.Grouped = group.groups[i]
+		}
+
+		if filter != nil {
+			ok, err := filter.Condition(r.grouped())
+			if err != nil {
+				return SelectedRecord{}, err
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		records = append(records, r)
+	}
+
+	var err error
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+
+		for _, o := range orderBy {
+			var c int
+
+			c, err = o.OrderBy(records[i], records[j])
+			if err != nil || c != 0 {
+				return c < 0
+			}
+		}
+
+		return false
+	})
+
+	if err != nil {
+		return SelectedRecord{}, err
+	}
+
+	group.Data = make([][]Value, len(records))
+
+	for i, r := range records {
+		group.Data[i] = r.Source.Values
+	}
+
+	if group.groups != nil {
+		group.groups = make([]Table, len(records))
+
+		for i, r := range records {
+			group.groups[i] = r.Grouped
+		}
+	}
+
+	record.Grouped = group
+
+	return record, nil
+}
+
+// sum switches from int64 to float64 when the integer sum overflows, since Postgres sums bigints as numeric.
+func sum(name string, values []Value) (Value, error) {
+	total := summation{name: name}
+
+	for _, v := range values {
+		if err := total.add(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return total.result()
+}
+
+type summation struct {
+	name      string
+	integer   int64
+	float     float64
+	interval  Interval
+	intervals int
+	numbers   int
+	isFloat   bool
+}
+
+func (s *summation) add(v Value) error {
+	switch kindOf(v) {
+	case kindInterval:
+		s.interval = s.interval.add(v.(Interval))
+		s.intervals++
+
+		return nil
+	case kindNumber:
+	default:
+		return fmt.Errorf("querify: function %s expects numbers or intervals, got type '%s'", s.name, kindOf(v))
+	}
+
+	s.numbers++
+
+	if i, ok := toInt(v); ok && !s.isFloat {
+		out, err := integerArithmetic(s.integer, i, "+")
+		if err == nil {
+			s.integer = out.(int64)
+
+			return nil
+		}
+	}
+
+	if !s.isFloat {
+		s.isFloat, s.float = true, float64(s.integer)
+	}
+
+	f, _ := toFloat(v)
+	s.float += f
+
+	return nil
+}
+
+func (s *summation) result() (Value, error) {
+	switch {
+	case s.numbers == 0:
+		return s.interval, nil
+	case s.intervals > 0:
+		return nil, fmt.Errorf("querify: function %s cannot mix numbers and intervals", s.name)
+	case s.isFloat:
+		return s.float, nil
+	}
+
+	return s.integer, nil
+}
+
+// mean divides the sum of numbers or intervals by their count.
+func mean(total Value, n int) Value {
+	if i, ok := total.(Interval); ok {
+		return i.scale(1 / float64(n))
+	}
+
+	f, _ := toFloat(total)
+
+	return f / float64(n)
+}
+
+// logical returns all, if no value differs from all, and NULL without values.
+func logical(record SelectedRecord, name string, expression Select, all bool) (Value, error) {
+	values, err := aggregate(record, false, expression)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	out := all
+
+	for _, v := range values {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("querify: function %s expects booleans, got type '%s'", name, kindOf(v))
+		}
+
+		if b != all {
+			out = b
+		}
+	}
+
+	return out, nil
+}
+
+// extremum compares the values like Greatest and Least.
+func extremum(values []Value, sign int) (Value, error) {
+	vars := make([]Variable, len(values))
+
+	for i, v := range values {
+		vars[i] = Literal{Value: v}
+	}
+
+	return extreme(vars, SelectedRecord{}, sign)
+}
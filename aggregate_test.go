@@ -0,0 +1,242 @@
+package querify_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestAggregates(t *testing.T) {
+	group := func(values ...querify.Value) querify.SelectedRecord {
+		data := make([][]querify.Value, len(values))
+
+		for i, v := range values {
+			data[i] = []querify.Value{v}
+		}
+
+		return querify.SelectedRecord{Grouped: querify.Table{Columns: []string{"v"}, Data: data}}
+	}
+
+	v := querify.Ident("v")
+
+	tests := []struct {
+		variable querify.Variable
+		record   querify.SelectedRecord
+		want     querify.Value
+	}{
+		{querify.Sum{Expression: v}, group(1, 2, nil, 3), int64(6)},
+		{querify.Sum{Expression: v}, group(1, 2.5), 3.5},
+		{querify.Sum{Distinct: true, Expression: v}, group(1, 1.0, 2), int64(3)},
+		{querify.Sum{Expression: v}, group(nil, nil), nil},
+		{querify.Sum{Expression: v}, group(), nil},
+		{querify.Sum{Expression: v}, group(int64(math.MaxInt64), 1), float64(math.MaxInt64) + 1},
+		{querify.Sum{Expression: v}, group(querify.Interval{Days: 1}, querify.Interval{Months: 1}),
+			querify.Interval{Months: 1, Days: 1}},
+		{querify.Avg{Expression: v}, group(1, 2, nil), 1.5},
+		{querify.Avg{Distinct: true, Expression: v}, group(1, 1, 4), 2.5},
+		{querify.Avg{Expression: v}, group(nil), nil},
+		{querify.Avg{Expression: v}, group(querify.Interval{Days: 1}, querify.Interval{Days: 2}),
+			querify.Interval{Days: 1, Duration: 12 * 3600e9}},
+		{querify.Min{Expression: v}, group(3, nil, 1.5, 2), 1.5},
+		{querify.Max{Expression: v}, group("b", "c", nil, "a"), "c"},
+		{querify.Max{Expression: v}, group(), nil},
+		{querify.Min{Expression: querify.Neg{v}}, group(1, 3), int64(-3)},
+		{querify.StringAgg{Expression: v, Delimiter: querify.Literal{Value: ", "}}, group("a", nil, 1, "a"), "a, 1, a"},
+		{
+			querify.StringAgg{Distinct: true, Expression: v, Delimiter: querify.Literal{Value: "-"}},
+			group("a", "b", "a"), "a-b",
+		},
+		{querify.StringAgg{Expression: v, Delimiter: querify.Literal{}}, group("a", "b"), "ab"},
+		{querify.StringAgg{Expression: v, Delimiter: querify.Literal{Value: ","}}, group(nil), nil},
+		{querify.JsonAgg{Expression: v}, group(), nil},
+		{querify.BoolAnd{Expression: v}, group(true, nil, true), true},
+		{querify.BoolAnd{Expression: v}, group(true, false), false},
+		{querify.BoolOr{Expression: v}, group(false, nil, true), true},
+		{querify.BoolOr{Expression: v}, group(false), false},
+		{querify.Every{Expression: v}, group(nil), nil},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Variable(test.record)
+		if err != nil {
+			t.Fatal(test.variable, err)
+		}
+
+		if got != test.want {
+			t.Fatalf("%v: %#v", test.variable, got)
+		}
+	}
+
+	got, err := querify.JsonAgg{Distinct: true, Expression: v}.Variable(group(1, nil, 1.0, "a", nil))
+	if err != nil || !reflect.DeepEqual(got, []interface{}{1, nil, "a"}) {
+		t.Fatal(got, err)
+	}
+
+	positive := querify.Greater{v, querify.Literal{Value: 0}}
+
+	for _, test := range []struct {
+		variable querify.Variable
+		want     querify.Value
+	}{
+		{querify.CountAll{Filter: positive}, 2},
+		{querify.CountAll{}, 4},
+		{querify.Count("v"), 3},
+		{querify.CountOf{Expression: v, Filter: positive}, 2},
+		{querify.CountOf{Distinct: true, Expression: querify.Mul{v, v}}, 2},
+		{querify.Sum{Expression: v, Filter: positive}, int64(5)},
+		{querify.Min{Expression: v, Filter: querify.Greater{v, querify.Literal{Value: 10}}}, nil},
+		{querify.StringAgg{
+			Expression: v, Delimiter: querify.Literal{Value: ","},
+			OrderBy: []querify.OrderBy{querify.Asc{Expression: v, NullsLast: true}},
+		}, "-3,2,3"},
+		{querify.StringAgg{
+			Expression: v, Delimiter: querify.Literal{Value: ","}, Filter: positive,
+			OrderBy: []querify.OrderBy{querify.Desc{Expression: v}},
+		}, "3,2"},
+	} {
+		got, err := test.variable.Variable(group(3, -3, nil, 2))
+		if err != nil || got != test.want {
+			t.Fatalf("%v: %#v %v", test.variable, got, err)
+		}
+	}
+
+	distinct := querify.ArrayAgg{Distinct: true, Expression: v, OrderBy: []querify.OrderBy{querify.Desc{Expression: v}}}
+
+	got, err = distinct.Variable(group(1, 3, nil, 1, 2))
+	if err != nil || !reflect.DeepEqual(got, []querify.Value{nil, 3, 2, 1}) {
+		t.Fatal(got, err)
+	}
+
+	pairs := querify.SelectedRecord{Grouped: querify.Table{Columns: []string{"k", "v"}, Data: [][]querify.Value{
+		{"a", 1}, {2, nil}, {"a", 3},
+	}}}
+
+	got, err = querify.JsonObjectAgg{Key: querify.Ident("k"), Value: querify.Ident("v")}.Variable(pairs)
+	if err != nil || !reflect.DeepEqual(got, map[string]interface{}{"a": 3, "2": nil}) {
+		t.Fatal(got, err)
+	}
+
+	for _, test := range []struct {
+		variable querify.Variable
+		record   querify.SelectedRecord
+	}{
+		{querify.JsonObjectAgg{Key: querify.Ident("v"), Value: querify.Ident("v")}, group("a", nil)},
+		{querify.BoolOr{Expression: v}, group(1)},
+		{querify.Sum{Expression: v}, group(1, "a")},
+		{querify.Avg{Expression: v}, group(true)},
+		{querify.Sum{Expression: v}, group(1, querify.Interval{Days: 1})},
+		{querify.Max{Expression: v}, group(1, "a")},
+	} {
+		if _, err := test.variable.Variable(test.record); err == nil {
+			t.Fatal(test.variable)
+		}
+	}
+}
+
+func TestParseAggregates(t *testing.T) {
+	data := tables()
+	data["orders"] = querify.From([]map[string]interface{}{
+		{"id": 1, "customer": "Max", "amount": 10, "quantity": 2},
+		{"id": 2, "customer": "Max", "amount": 20, "quantity": 1},
+		{"id": 3, "customer": "Tom", "amount": 5, "quantity": nil},
+		{"id": 4, "customer": "Alex", "amount": nil, "quantity": nil},
+	})
+
+	stmt, err := querify.Parse(`SELECT customer, sum(amount * quantity) AS total, avg(amount) AS average, `+
+		`min(amount) AS smallest, max(DISTINCT id) AS last, count(quantity) AS counted `+
+		`FROM orders GROUP BY customer ORDER BY customer`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"customer": "Alex", "total": nil, "average": nil, "smallest": nil, "last": 4.0, "counted": 0.0},
+		{"customer": "Max", "total": 40.0, "average": 15.0, "smallest": 10.0, "last": 2.0, "counted": 2.0},
+		{"customer": "Tom", "total": nil, "average": 5.0, "smallest": 5.0, "last": 3.0, "counted": 0.0},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, data)
+
+	stmt, err = querify.Parse(`SELECT string_agg(DISTINCT customer, ', ') AS customers, json_agg(amount) AS amounts, `+
+		`json_object_agg(id, quantity) AS quantities, every(CASE WHEN amount > 1 THEN true ELSE false END) AS large `+
+		`FROM orders WHERE id < 4`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out = nil
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []map[string]interface{}{{
+		"customers":  "Max, Tom",
+		"amounts":    []interface{}{10.0, 20.0, 5.0},
+		"quantities": map[string]interface{}{"1": 2.0, "2": 1.0, "3": nil},
+		"large":      true,
+	}}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, data)
+
+	stmt, err = querify.Parse(`SELECT customer, count(*) FILTER (WHERE amount >= 10) AS large, `+
+		`array_agg(id ORDER BY amount DESC NULLS LAST, id) AS ids, count(DISTINCT customer) AS customers, `+
+		`string_agg(id::text, ',' ORDER BY id DESC) FILTER (WHERE quantity IS NOT NULL) AS counted `+
+		`FROM orders GROUP BY customer ORDER BY customer`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out = nil
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []map[string]interface{}{
+		{"customer": "Alex", "large": 0.0, "ids": []interface{}{4.0}, "customers": 1.0, "counted": nil},
+		{"customer": "Max", "large": 2.0, "ids": []interface{}{2.0, 1.0}, "customers": 1.0, "counted": "2,1"},
+		{"customer": "Tom", "large": 0.0, "ids": []interface{}{3.0}, "customers": 1.0, "counted": nil},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, data)
+
+	if _, err = querify.Parse(`SELECT lower(name) FILTER (WHERE id > 1) FROM users`, data); err == nil {
+		t.Fatal(err)
+	}
+
+	stmt, err = querify.Parse(`SELECT sum(amount) AS total, count(*) AS count FROM orders WHERE id > 10`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out = nil
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, []map[string]interface{}{{"total": nil, "count": 0.0}}) {
+		t.Fatal(out)
+	}
+}
@@ -0,0 +1,265 @@
+package querify
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type Add [2]Variable
+
+func (a Add) Variable(record SelectedRecord) (Value, error) {
+	return arithmetic(a[0], a[1], "+", record)
+}
+
+func (a Add) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", a, table)
+}
+
+func (a Add) SQL() (string, error) {
+	return renderArithmetic(a[0], "+", a[1], precedence(a))
+}
+
+type Sub [2]Variable
+
+func (s Sub) Variable(record SelectedRecord) (Value, error) {
+	return arithmetic(s[0], s[1], "-", record)
+}
+
+func (s Sub) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", s, table)
+}
+
+func (s Sub) SQL() (string, error) {
+	return renderArithmetic(s[0], "-", s[1], precedence(s))
+}
+
+type Mul [2]Variable
+
+func (m Mul) Variable(record SelectedRecord) (Value, error) {
+	return arithmetic(m[0], m[1], "*", record)
+}
+
+func (m Mul) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", m, table)
+}
+
+func (m Mul) SQL() (string, error) {
+	return renderArithmetic(m[0], "*", m[1], precedence(m))
+}
+
+type Div [2]Variable
+
+func (d Div) Variable(record SelectedRecord) (Value, error) {
+	return arithmetic(d[0], d[1], "/", record)
+}
+
+func (d Div) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", d, table)
+}
+
+func (d Div) SQL() (string, error) {
+	return renderArithmetic(d[0], "/", d[1], precedence(d))
+}
+
+type Mod [2]Variable
+
+func (m Mod) Variable(record SelectedRecord) (Value, error) {
+	return arithmetic(m[0], m[1], "%", record)
+}
+
+func (m Mod) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", m, table)
+}
+
+func (m Mod) SQL() (string, error) {
+	return renderArithmetic(m[0], "%", m[1], precedence(m))
+}
+
+type Neg [1]Variable
+
+func (n Neg) Variable(record SelectedRecord) (Value, error) {
+	value, err := n[0].Variable(record)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	if i, ok := toInt(value); ok {
+		if i == math.MinInt64 {
+			return nil, fmt.Errorf("querify: integer out of range")
+		}
+
+		return -i, nil
+	}
+
+	if f, ok := toFloat(value); ok {
+		return -f, nil
+	}
+
+	if i, ok := value.(Interval); ok {
+		return i.scale(-1), nil
+	}
+
+	return nil, fmt.Errorf("querify: cannot negate type '%s'", kindOf(value))
+}
+
+func (n Neg) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", n, table)
+}
+
+func (n Neg) SQL() (string, error) {
+	s, err := render(n[0])
+	if err != nil {
+		return "", err
+	}
+
+	if precedence(n[0]) > 0 || strings.HasPrefix(s, "-") {
+		return "-(" + s + ")", nil
+	}
+
+	return "-" + s, nil
+}
+
+func selectVariable(name string, variable Variable, table SelectedTable) (string, []Value, error) {
+	out := make([]Value, len(table.Source.Data))
+
+	for i := range table.Source.Data {
+		value, err := variable.Variable(table.Record(i))
+		if err != nil {
+			return "", nil, err
+		}
+
+		out[i] = value
+	}
+
+	return name, out, nil
+}
+
+// arithmetic computes integers as int64 and other numbers as float64.
+func arithmetic(left, right Variable, op string, record SelectedRecord) (Value, error) {
+	l, err := left.Variable(record)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := right.Variable(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if l == nil || r == nil {
+		return nil, nil
+	}
+
+	if kindOf(l) == kindTime || kindOf(l) == kindInterval || kindOf(r) == kindTime || kindOf(r) == kindInterval {
+		return temporal(l, r, op)
+	}
+
+	if kindOf(l) != kindNumber || kindOf(r) != kindNumber {
+		return nil, fmt.Errorf("querify: cannot apply '%s' to types '%s' and '%s'", op, kindOf(l), kindOf(r))
+	}
+
+	if a, ok := toInt(l); ok {
+		if b, ok := toInt(r); ok {
+			return integerArithmetic(a, b, op)
+		}
+	}
+
+	a, _ := toFloat(l)
+	b, _ := toFloat(r)
+
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	}
+
+	if b == 0 {
+		return nil, fmt.Errorf("querify: division by zero")
+	}
+
+	if op == "/" {
+		return a / b, nil
+	}
+
+	return math.Mod(a, b), nil
+}
+
+func integerArithmetic(a, b int64, op string) (Value, error) {
+	var out int64
+
+	switch op {
+	case "+":
+		out = a + b
+		if (out > a) != (b > 0) {
+			return nil, fmt.Errorf("querify: integer out of range")
+		}
+	case "-":
+		out = a - b
+		if (out < a) != (b > 0) {
+			return nil, fmt.Errorf("querify: integer out of range")
+		}
+	case "*":
+		out = a * b
+		if a != 0 && (out/a != b || a == -1 && b == math.MinInt64) {
+			return nil, fmt.Errorf("querify: integer out of range")
+		}
+	default:
+		if b == 0 {
+			return nil, fmt.Errorf("querify: division by zero")
+		}
+
+		if a == math.MinInt64 && b == -1 {
+			if op == "%" {
+				return int64(0), nil
+			}
+
+			return nil, fmt.Errorf("querify: integer out of range")
+		}
+
+		if op == "/" {
+			out = a / b
+		} else {
+			out = a % b
+		}
+	}
+
+	return out, nil
+}
+
+// precedence returns the binding strength of arithmetic operators and 0 for all other variables.
+func precedence(variable Variable) int {
+	switch variable.(type) {
+	case Add, Sub:
+		return 1
+	case Mul, Div, Mod:
+		return 2
+	}
+
+	return 0
+}
+
+func renderArithmetic(left Variable, op string, right Variable, p int) (string, error) {
+	l, err := render(left)
+	if err != nil {
+		return "", err
+	}
+
+	if lp := precedence(left); lp > 0 && lp < p {
+		l = "(" + l + ")"
+	}
+
+	r, err := render(right)
+	if err != nil {
+		return "", err
+	}
+
+	if rp := precedence(right); rp > 0 && rp <= p {
+		r = "(" + r + ")"
+	}
+
+	return l + " " + op + " " + r, nil
+}
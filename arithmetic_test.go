@@ -0,0 +1,92 @@
+package querify_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestArithmetic(t *testing.T) {
+	record := querify.SelectedRecord{Source: querify.Record{
+		Columns: []string{"int", "float", "null", "text"},
+		Values:  []querify.Value{7, 2.0, nil, "a"},
+	}}
+
+	i, f := querify.Ident("int"), querify.Ident("float")
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+
+	tests := []struct {
+		variable querify.Variable
+		want     querify.Value
+	}{
+		{querify.Add{i, lit(1)}, int64(8)},
+		{querify.Add{i, f}, 9.0},
+		{querify.Sub{i, lit(10)}, int64(-3)},
+		{querify.Mul{i, lit(uint8(3))}, int64(21)},
+		{querify.Div{i, lit(2)}, int64(3)},
+		{querify.Div{i, f}, 3.5},
+		{querify.Div{lit(-7), lit(2)}, int64(-3)},
+		{querify.Mod{i, lit(4)}, int64(3)},
+		{querify.Mod{i, lit(2.5)}, 2.0},
+		{querify.Neg{i}, int64(-7)},
+		{querify.Neg{f}, -2.0},
+		{querify.Add{i, querify.Ident("null")}, nil},
+		{querify.Div{querify.Ident("null"), lit(0)}, nil},
+		{querify.Neg{querify.Ident("null")}, nil},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Variable(record)
+		if err != nil {
+			t.Fatal(test.variable, err)
+		}
+
+		if got != test.want {
+			t.Fatalf("%v: %#v", test.variable, got)
+		}
+	}
+
+	for _, v := range []querify.Variable{
+		querify.Div{i, lit(0)},
+		querify.Div{f, lit(0.0)},
+		querify.Mod{i, lit(0)},
+		querify.Add{i, querify.Ident("text")},
+		querify.Neg{querify.Ident("text")},
+		querify.Add{lit(int64(math.MaxInt64)), lit(1)},
+		querify.Mul{lit(int64(math.MinInt64)), lit(-1)},
+	} {
+		if _, err := v.Variable(record); err == nil {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestParseArithmetic(t *testing.T) {
+	data := tables()
+	data["orders"] = querify.From([]map[string]interface{}{
+		{"id": 1, "price": 2.5, "quantity": 4, "discount": 1},
+		{"id": 2, "price": 10, "quantity": 1, "discount": nil},
+	})
+
+	stmt, err := querify.Parse(`SELECT id, price * quantity - discount AS total, -(id + 1) * 2 AS neg `+
+		`FROM orders WHERE (price + 1) * quantity > 10 - id % 2 ORDER BY total`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{{"id": 1.0, "total": 9.0, "neg": -4.0}, {"id": 2.0, "total": nil, "neg": -6.0}}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, data)
+}
@@ -0,0 +1,72 @@
+package querify
+
+import (
+	"strings"
+)
+
+type WhenThen struct {
+	When Condition
+	Then Variable
+}
+
+// Case returns the result of the first branch whose condition is true, or Else. Without Else it returns NULL.
+type Case struct {
+	When []WhenThen
+	Else Variable
+}
+
+func (c Case) Variable(record SelectedRecord) (Value, error) {
+	for _, w := range c.When {
+		ok, err := w.When.Condition(record.grouped())
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return w.Then.Variable(record)
+		}
+	}
+
+	if c.Else == nil {
+		return nil, nil
+	}
+
+	return c.Else.Variable(record)
+}
+
+func (c Case) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("case", c, table)
+}
+
+func (c Case) SQL() (string, error) {
+	b := strings.Builder{}
+
+	b.WriteString("CASE")
+
+	for _, w := range c.When {
+		when, err := render(w.When)
+		if err != nil {
+			return "", err
+		}
+
+		then, err := render(w.Then)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(" WHEN " + when + " THEN " + then)
+	}
+
+	if c.Else != nil {
+		e, err := render(c.Else)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(" ELSE " + e)
+	}
+
+	b.WriteString(" END")
+
+	return b.String(), nil
+}
@@ -0,0 +1,85 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestCase(t *testing.T) {
+	label := querify.Case{
+		When: []querify.WhenThen{
+			{When: querify.Less{querify.Ident("id"), querify.Literal{Value: 2}}, Then: querify.Literal{Value: "low"}},
+			{When: querify.Equals{querify.Ident("id"), querify.Literal{Value: 2}}, Then: querify.Literal{Value: "mid"}},
+		},
+		Else: querify.Literal{Value: "high"},
+	}
+
+	var labels []string
+
+	err := tables()["users"].Query().
+		Select(querify.As{Name: "label", Expression: label}).
+		OrderBy(querify.Desc{Expression: querify.Case{When: []querify.WhenThen{
+			{When: querify.Equals{querify.Ident("name"), querify.Literal{Value: "Tom"}}, Then: querify.Literal{Value: 1}},
+		}, Else: querify.Literal{Value: 0}}}).
+		ScanColumn("label", &labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(labels, []string{"mid", "low", "high"}) {
+		t.Fatal(labels)
+	}
+
+	var ids []int
+
+	id, double := querify.Ident("id"), querify.Ident("double")
+
+	// The alias double is only visible in the selected record.
+	err = tables()["users"].Query().
+		Select(id, querify.As{Name: "double", Expression: querify.Mul{id, querify.Literal{Value: 2}}}).
+		OrderBy(querify.Asc{Expression: querify.Case{When: []querify.WhenThen{
+			{When: querify.Greater{double, querify.Literal{Value: 3}}, Then: querify.Neg{id}},
+		}, Else: id}}).
+		ScanColumn("id", &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ids, []int{3, 2, 1}) {
+		t.Fatal(ids)
+	}
+
+	value, err := querify.Case{When: []querify.WhenThen{
+		{When: querify.Equals{querify.Literal{}, querify.Literal{}}, Then: querify.Literal{Value: 1}},
+	}}.Variable(querify.SelectedRecord{})
+	if err != nil || value != nil {
+		t.Fatal(value, err)
+	}
+}
+
+func TestParseCase(t *testing.T) {
+	stmt, err := querify.Parse(`SELECT hobby_id, `+
+		`array_agg(CASE user_id WHEN 1 THEN 'Max' WHEN 2 THEN 'Tom' END) AS names `+
+		`FROM user_hobbies GROUP BY hobby_id `+
+		`ORDER BY CASE WHEN hobby_id = 3 THEN 0 ELSE hobby_id END`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []struct {
+		HobbyID int `json:"hobby_id"`
+		Names   []interface{}
+	}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 3 || out[0].HobbyID != 3 || !reflect.DeepEqual(out[1].Names, []interface{}{"Max", nil}) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, tables())
+}
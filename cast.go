@@ -0,0 +1,561 @@
+package querify
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cast converts a value to a Postgres type like 'numeric(10,2)' or 'int[]'.
+type Cast struct {
+	Expression Variable
+	Type       string
+}
+
+func (c Cast) Variable(record SelectedRecord) (Value, error) {
+	value, err := c.Expression.Variable(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return convert(value, c.Type)
+}
+
+func (c Cast) Select(table SelectedTable) (string, []Value, error) {
+	name, _, _, _ := castType(c.Type)
+
+	if i, ok := c.Expression.(Ident); ok {
+		name = string(i[strings.LastIndex(string(i), ".")+1:])
+	}
+
+	return selectVariable(name, c, table)
+}
+
+func (c Cast) SQL() (string, error) {
+	s, err := render(c.Expression)
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := c.Expression.(Neg); ok || precedence(c.Expression) > 0 || strings.HasPrefix(s, "-") {
+		s = "(" + s + ")"
+	}
+
+	return s + "::" + c.Type, nil
+}
+
+// castType returns the lowercase type name, its modifiers and whether it is an array type.
+func castType(typ string) (string, []int, bool, error) {
+	t := strings.ToLower(strings.TrimSpace(typ))
+
+	if strings.HasSuffix(t, "[]") {
+		return strings.TrimSpace(strings.TrimSuffix(t, "[]")), nil, true, nil
+	}
+
+	i := strings.IndexByte(t, '(')
+	if i < 0 {
+		return t, nil, false, nil
+	}
+
+	j := strings.IndexByte(t[i:], ')')
+	if j < 0 {
+		return "", nil, false, fmt.Errorf("invalid type modifier")
+	}
+
+	var modifiers []int
+
+	for _, m := range strings.Split(t[i+1:i+j], ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(m))
+		if err != nil {
+			return "", nil, false, fmt.Errorf("invalid type modifier")
+		}
+
+		modifiers = append(modifiers, n)
+	}
+
+	return strings.Join(strings.Fields(t[:i]+" "+t[i+j+1:]), " "), modifiers, false, nil
+}
+
+func checkType(typ string) error {
+	t, modifiers, array, err := castType(typ)
+	if err != nil {
+		return err
+	}
+
+	if array {
+		return checkType(t)
+	}
+
+	if _, ok := casts[t]; !ok {
+		return fmt.Errorf("type %s does not exist", typ)
+	}
+
+	_, err = modifier(t, modifiers)
+
+	return err
+}
+
+// modifier returns a function, that applies the modifiers of a type to a converted value.
+func modifier(typ string, modifiers []int) (func(value Value) (Value, error), error) {
+	switch typ {
+	case "numeric", "decimal":
+		return numericModifier(modifiers)
+	case "varchar", "character varying", "char", "character":
+		if len(modifiers) == 0 && (typ == "char" || typ == "character") {
+			modifiers = []int{1}
+		}
+
+		return lengthModifier(typ, modifiers)
+	case "timestamp", "timestamp without time zone", "timestamptz", "timestamp with time zone", "interval":
+		return precisionModifier(typ, modifiers)
+	case "float":
+		if len(modifiers) == 1 && modifiers[0] >= 1 && modifiers[0] <= 53 {
+			return nil, nil
+		}
+	}
+
+	if len(modifiers) > 0 {
+		return nil, fmt.Errorf("invalid type modifier for type %s", typ)
+	}
+
+	return nil, nil
+}
+
+// numericModifier rounds numbers to the scale and rejects numbers, that exceed the precision.
+func numericModifier(modifiers []int) (func(value Value) (Value, error), error) {
+	if len(modifiers) == 0 {
+		return nil, nil
+	}
+
+	precision, scale := modifiers[0], 0
+
+	if len(modifiers) == 2 {
+		scale = modifiers[1]
+	}
+
+	if len(modifiers) > 2 || precision < 1 || precision > 1000 || scale < 0 || scale > precision {
+		return nil, fmt.Errorf("invalid type modifier for type numeric")
+	}
+
+	return func(value Value) (Value, error) {
+		pow := math.Pow10(scale)
+		f := math.Round(value.(float64)*pow) / pow
+
+		if math.Abs(f) >= math.Pow10(precision-scale) {
+			return nil, fmt.Errorf("numeric field overflow")
+		}
+
+		return f, nil
+	}, nil
+}
+
+// lengthModifier truncates strings to the length. Unlike Postgres, char values aren't padded with spaces.
+func lengthModifier(typ string, modifiers []int) (func(value Value) (Value, error), error) {
+	if len(modifiers) == 0 {
+		return nil, nil
+	}
+
+	if len(modifiers) > 1 || modifiers[0] < 1 {
+		return nil, fmt.Errorf("invalid type modifier for type %s", typ)
+	}
+
+	return func(value Value) (Value, error) {
+		if r := []rune(value.(string)); len(r) > modifiers[0] {
+			return string(r[:modifiers[0]]), nil
+		}
+
+		return value, nil
+	}, nil
+}
+
+// precisionModifier rounds timestamps and intervals to the number of fractional digits of seconds.
+func precisionModifier(typ string, modifiers []int) (func(value Value) (Value, error), error) {
+	if len(modifiers) == 0 {
+		return nil, nil
+	}
+
+	if len(modifiers) > 1 || modifiers[0] < 0 || modifiers[0] > 6 {
+		return nil, fmt.Errorf("invalid type modifier for type %s", typ)
+	}
+
+	unit := time.Duration(math.Pow10(9 - modifiers[0]))
+
+	return func(value Value) (Value, error) {
+		if i, ok := value.(Interval); ok {
+			i.Duration = i.Duration.Round(unit)
+
+			return i, nil
+		}
+
+		return value.(time.Time).Round(unit), nil
+	}, nil
+}
+
+var casts = map[string]func(value Value) (Value, error){
+	"smallint":                    castInt(16),
+	"int2":                        castInt(16),
+	"int":                         castInt(32),
+	"integer":                     castInt(32),
+	"int4":                        castInt(32),
+	"bigint":                      castInt(64),
+	"int8":                        castInt(64),
+	"numeric":                     castFloat,
+	"decimal":                     castFloat,
+	"real":                        castFloat,
+	"float":                       castFloat,
+	"float4":                      castFloat,
+	"float8":                      castFloat,
+	"double precision":            castFloat,
+	"text":                        castText,
+	"varchar":                     castText,
+	"character varying":           castText,
+	"char":                        castText,
+	"character":                   castText,
+	"boolean":                     castBool,
+	"bool":                        castBool,
+	"date":                        castDate,
+	"timestamp":                   castLocalTimestamp,
+	"timestamp without time zone": castLocalTimestamp,
+	"timestamptz":                 castTimestamp,
+	"timestamp with time zone":    castTimestamp,
+	"interval":                    castInterval,
+	"json":                        castJSON,
+	"jsonb":                       castJSON,
+}
+
+func convert(value Value, typ string) (Value, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	t, modifiers, array, err := castType(typ)
+	if err != nil {
+		return nil, fmt.Errorf("querify: %w", err)
+	}
+
+	if array {
+		return castArray(value, t)
+	}
+
+	cast, ok := casts[t]
+	if !ok {
+		return nil, fmt.Errorf("querify: unknown type '%s'", typ)
+	}
+
+	apply, err := modifier(t, modifiers)
+	if err != nil {
+		return nil, fmt.Errorf("querify: %w", err)
+	}
+
+	out, err := cast(value)
+	if err == nil && apply != nil {
+		out, err = apply(out)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("querify: cannot cast '%v' to %s: %w", value, typ, err)
+	}
+
+	return out, nil
+}
+
+func castInt(bits uint) func(value Value) (Value, error) {
+	return func(value Value) (Value, error) {
+		var i int64
+
+		switch v := value.(type) {
+		case bool:
+			if v {
+				i = 1
+			}
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer")
+			}
+
+			i = n
+		default:
+			n, ok := toInt(v)
+			if !ok {
+				f, ok := toFloat(v)
+				if !ok {
+					return nil, fmt.Errorf("unsupported type '%s'", kindOf(v))
+				}
+
+				if math.IsNaN(f) || math.Abs(f) >= math.MaxInt64 {
+					return nil, fmt.Errorf("out of range")
+				}
+
+				n = int64(math.Round(f))
+			}
+
+			i = n
+		}
+
+		if bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+			return nil, fmt.Errorf("out of range")
+		}
+
+		return i, nil
+	}
+}
+
+func castFloat(value Value) (Value, error) {
+	if s, ok := value.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number")
+		}
+
+		return f, nil
+	}
+
+	f, ok := toFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type '%s'", kindOf(value))
+	}
+
+	return f, nil
+}
+
+// castText formats whole numbers without exponent, because numbers decoded from JSON are float64.
+func castText(value Value) (Value, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case Interval:
+		return v.String(), nil
+	}
+
+	if i, ok := toInt(value); ok {
+		return strconv.FormatInt(i, 10), nil
+	}
+
+	if f, ok := toFloat(value); ok {
+		if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func castBool(value Value) (Value, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "t", "true", "y", "yes", "on", "1":
+			return true, nil
+		case "f", "false", "n", "no", "off", "0":
+			return false, nil
+		}
+
+		return nil, fmt.Errorf("invalid boolean")
+	}
+
+	f, ok := toFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type '%s'", kindOf(value))
+	}
+
+	return f != 0, nil
+}
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04Z07:00",
+	"2006-01-02 15:04Z07",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// castTimestamp converts timestamps to UTC like Postgres with the UTC time zone.
+func castTimestamp(value Value) (Value, error) {
+	t, err := parseTimestamp(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.UTC(), nil
+}
+
+func parseTimestamp(value Value) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, nil
+			}
+		}
+
+		return time.Time{}, fmt.Errorf("invalid timestamp")
+	}
+
+	return time.Time{}, fmt.Errorf("unsupported type '%s'", kindOf(value))
+}
+
+// castLocalTimestamp keeps the date and time of strings without their offset and converts timestamps to UTC.
+func castLocalTimestamp(value Value) (Value, error) {
+	t, err := parseTimestamp(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := value.(time.Time); ok {
+		return t.UTC(), nil
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), nil
+}
+
+// castDate truncates timestamps to midnight UTC.
+func castDate(value Value) (Value, error) {
+	t, err := castLocalTimestamp(value)
+	if err != nil {
+		return nil, err
+	}
+
+	year, month, day := t.(time.Time).Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+}
+
+func castJSON(value Value) (Value, error) {
+	if s, ok := value.(string); ok {
+		var out interface{}
+
+		if err := json.Unmarshal([]byte(s), &out); err != nil {
+			return nil, fmt.Errorf("invalid json")
+		}
+
+		return out, nil
+	}
+
+	return normalize(value)
+}
+
+// castArray accepts arrays, JSON arrays and Postgres array literals like '{1,2,"a b",NULL}'.
+func castArray(value Value, typ string) (Value, error) {
+	value, err := normalize(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := value.(string); ok {
+		s = strings.TrimSpace(s)
+
+		switch {
+		case strings.HasPrefix(s, "["):
+			value, err = castJSON(s)
+		case strings.HasPrefix(s, "{"):
+			value, err = parseArrayLiteral(s)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("querify: cannot cast '%s' to %s[]: %w", s, typ, err)
+		}
+	}
+
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("querify: cannot cast '%v' to %s[]", value, typ)
+	}
+
+	out := make([]interface{}, len(array))
+
+	for i, v := range array {
+		out[i], err = convert(v, typ)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+func parseArrayLiteral(s string) ([]interface{}, error) {
+	if !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("invalid array")
+	}
+
+	s = strings.TrimSpace(s[1 : len(s)-1])
+
+	out := []interface{}{}
+
+	for len(s) > 0 {
+		var element interface{}
+
+		if s[0] == '"' {
+			b := strings.Builder{}
+			i := 1
+
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+
+				b.WriteByte(s[i])
+			}
+
+			if i == len(s) {
+				return nil, fmt.Errorf("invalid array")
+			}
+
+			element, s = b.String(), strings.TrimSpace(s[i+1:])
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+
+			text := strings.TrimSpace(s[:end])
+			if text == "" || strings.ContainsAny(text, "{}") {
+				return nil, fmt.Errorf("invalid array")
+			}
+
+			if !strings.EqualFold(text, "null") {
+				element = text
+			}
+
+			s = s[end:]
+		}
+
+		out = append(out, element)
+
+		if len(s) > 0 {
+			if s[0] != ',' {
+				return nil, fmt.Errorf("invalid array")
+			}
+
+			s = strings.TrimSpace(s[1:])
+			if s == "" {
+				return nil, fmt.Errorf("invalid array")
+			}
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,149 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wroge/querify"
+)
+
+func TestCast(t *testing.T) {
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+	date := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		cast querify.Cast
+		want querify.Value
+	}{
+		{querify.Cast{Expression: lit(" 42 "), Type: "int"}, int64(42)},
+		{querify.Cast{Expression: lit(2.5), Type: "bigint"}, int64(3)},
+		{querify.Cast{Expression: lit(true), Type: "integer"}, int64(1)},
+		{querify.Cast{Expression: lit("1.5"), Type: "numeric(10,2)"}, 1.5},
+		{querify.Cast{Expression: lit(3.14159), Type: "numeric(5,2)"}, 3.14},
+		{querify.Cast{Expression: lit(-2.5), Type: "decimal(3)"}, -3.0},
+		{querify.Cast{Expression: lit("abcdef"), Type: "varchar(3)"}, "abc"},
+		{querify.Cast{Expression: lit("xyz"), Type: "char"}, "x"},
+		{querify.Cast{Expression: lit("xyz"), Type: "character varying"}, "xyz"},
+		{querify.Cast{Expression: lit("2021-03-04 00:00:00.6"), Type: "timestamp(0)"}, date.Add(time.Second)},
+		{querify.Cast{Expression: lit("2021-03-04T02:00:00+02:00"), Type: "timestamp"}, date.Add(2 * time.Hour)},
+		{querify.Cast{Expression: lit(date.In(time.FixedZone("", 3600))), Type: "timestamp"}, date},
+		{querify.Cast{Expression: lit("2021-03-04T02:00:00+02:00"), Type: "timestamptz"}, date},
+		{querify.Cast{Expression: lit(date.In(time.FixedZone("", 3600))), Type: "timestamp with time zone"}, date},
+		{
+			querify.Cast{Expression: querify.Cast{Expression: lit("2021-03-04 01:00+02"), Type: "timestamptz"}, Type: "text"},
+			"2021-03-03T23:00:00Z",
+		},
+		{querify.Cast{Expression: lit(3), Type: "double precision"}, 3.0},
+		{querify.Cast{Expression: lit(3.0), Type: "text"}, "3"},
+		{querify.Cast{Expression: lit(0.5), Type: "varchar"}, "0.5"},
+		{querify.Cast{Expression: lit(false), Type: "text"}, "false"},
+		{querify.Cast{Expression: lit("yes"), Type: "boolean"}, true},
+		{querify.Cast{Expression: lit(0.0), Type: "bool"}, false},
+		{querify.Cast{Expression: lit("2021-03-04"), Type: "date"}, date},
+		{querify.Cast{Expression: lit("2021-03-04T10:00:00Z"), Type: "date"}, date},
+		{querify.Cast{Expression: lit("2021-03-04 00:00:00"), Type: "timestamp"}, date},
+		{querify.Cast{Expression: lit(date), Type: "text"}, "2021-03-04T00:00:00Z"},
+		{querify.Cast{Expression: lit(nil), Type: "int"}, nil},
+	}
+
+	for _, test := range tests {
+		got, err := test.cast.Variable(querify.SelectedRecord{})
+		if err != nil {
+			t.Fatal(test.cast, err)
+		}
+
+		if got != test.want {
+			t.Fatalf("%v: %#v", test.cast, got)
+		}
+	}
+
+	collections := []struct {
+		cast querify.Cast
+		want querify.Value
+	}{
+		{querify.Cast{Expression: lit(`{"a": [1]}`), Type: "jsonb"}, map[string]interface{}{"a": []interface{}{1.0}}},
+		{querify.Cast{Expression: lit(`{1, 2,NULL}`), Type: "int[]"}, []interface{}{int64(1), int64(2), nil}},
+		{querify.Cast{Expression: lit(`{"a,b", c}`), Type: "text[]"}, []interface{}{"a,b", "c"}},
+		{
+			querify.Cast{Expression: lit(`[[1], [2.5]]`), Type: "float[][]"},
+			[]interface{}{[]interface{}{1.0}, []interface{}{2.5}},
+		},
+		{querify.Cast{Expression: lit([]int{1, 0}), Type: "bool[]"}, []interface{}{true, false}},
+	}
+
+	for _, test := range collections {
+		got, err := test.cast.Variable(querify.SelectedRecord{})
+		if err != nil {
+			t.Fatal(test.cast, err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%v: %#v", test.cast, got)
+		}
+	}
+
+	for _, c := range []querify.Cast{
+		{Expression: lit("1.5"), Type: "int"},
+		{Expression: lit(1 << 40), Type: "int"},
+		{Expression: lit("x"), Type: "float"},
+		{Expression: lit("maybe"), Type: "boolean"},
+		{Expression: lit("2021-13-01"), Type: "date"},
+		{Expression: lit(1), Type: "date"},
+		{Expression: lit("{"), Type: "json"},
+		{Expression: lit("1,2"), Type: "int[]"},
+		{Expression: lit("{1,}"), Type: "int[]"},
+		{Expression: lit(1), Type: "money"},
+		{Expression: lit(123.4), Type: "numeric(4,2)"},
+		{Expression: lit(1), Type: "int(4)"},
+		{Expression: lit(1), Type: "numeric(2,3)"},
+		{Expression: lit("a"), Type: "varchar(0)"},
+	} {
+		if _, err := c.Variable(querify.SelectedRecord{}); err == nil {
+			t.Fatal(c)
+		}
+	}
+}
+
+func TestParseCast(t *testing.T) {
+	data := tables()
+	data["ids"] = querify.From([]map[string]interface{}{
+		{"user_id": "1", "since": "2020-01-02"},
+		{"user_id": "3", "since": "2021-05-06T07:08:09Z"},
+	})
+
+	stmt, err := querify.Parse(`SELECT u.name, ids.since::date AS since, CAST(u.id AS text) || '!' AS tag `+
+		`FROM users u JOIN ids ON u.id = ids.user_id::int WHERE ids.since::timestamp > '2020-06-01'::date `+
+		`OR -u.id::int < -2`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []struct {
+		Name  string
+		Since time.Time
+		Tag   string
+	}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Date(2021, 5, 6, 0, 0, 0, 0, time.UTC)
+
+	if len(out) != 1 || out[0].Name != "Alex" || out[0].Tag != "3!" || !out[0].Since.Equal(since) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, data)
+
+	for _, sql := range []string{
+		`SELECT id::money FROM users`,
+		`SELECT id::int(4) FROM users`,
+		`SELECT CAST(id AS varchar(0)) FROM users`,
+	} {
+		if _, err = querify.Parse(sql, tables()); err == nil {
+			t.Fatal(sql)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package querify
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBool
+	kindNumber
+	kindString
+	kindArray
+	kindObject
+	kindTime
+	kindInterval
+)
+
+var kinds = [...]string{"null", "boolean", "number", "string", "array", "object", "timestamp", "interval"}
+
+func (k kind) String() string {
+	return kinds[k]
+}
+
+func kindOf(value Value) kind {
+	switch value.(type) {
+	case nil:
+		return kindNull
+	case bool:
+		return kindBool
+	case string:
+		return kindString
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return kindNumber
+	case []interface{}:
+		return kindArray
+	case time.Time:
+		return kindTime
+	case Interval:
+		return kindInterval
+	}
+
+	return kindObject
+}
+
+// normalize converts values of unknown Go types into their JSON representation.
+func normalize(value Value) (Value, error) {
+	switch value.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64,
+		[]interface{}, map[string]interface{}, time.Time, Interval:
+		return value, nil
+	}
+
+	return Copy(value)
+}
+
+func toInt(value Value) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	}
+
+	return 0, false
+}
+
+func toFloat(value Value) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+
+	i, ok := toInt(value)
+
+	return float64(i), ok
+}
+
+func compareNumbers(a, b Value) int {
+	if ia, ok := toInt(a); ok {
+		if ib, ok := toInt(b); ok {
+			switch {
+			case ia < ib:
+				return -1
+			case ia > ib:
+				return 1
+			}
+
+			return 0
+		}
+	}
+
+	fa, _ := toFloat(a)
+	fb, _ := toFloat(b)
+
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+
+	return 0
+}
+
+// compare is shared by all comparison conditions, Asc and Desc. Values of different kinds are an error.
+func compare(a, b Value) (int, error) {
+	a, err := normalize(a)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err = normalize(b)
+	if err != nil {
+		return 0, err
+	}
+
+	a, b, err = coerce(a, b)
+	if err != nil {
+		return 0, err
+	}
+
+	ka, kb := kindOf(a), kindOf(b)
+	if ka != kb {
+		return 0, fmt.Errorf("querify: cannot compare types '%s' and '%s'", ka, kb)
+	}
+
+	switch ka {
+	case kindBool:
+		switch {
+		case a == b:
+			return 0, nil
+		case b.(bool):
+			return -1, nil
+		}
+
+		return 1, nil
+	case kindNumber:
+		return compareNumbers(a, b), nil
+	case kindString:
+		return strings.Compare(a.(string), b.(string)), nil
+	case kindArray:
+		return compareArrays(a.([]interface{}), b.([]interface{}))
+	case kindTime:
+		switch ta, tb := a.(time.Time), b.(time.Time); {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		}
+
+		return 0, nil
+	case kindInterval:
+		return compareNumbers(a.(Interval).microseconds(), b.(Interval).microseconds()), nil
+	case kindObject:
+		ja, err := json.Marshal(a)
+		if err != nil {
+			return 0, err
+		}
+
+		jb, err := json.Marshal(b)
+		if err != nil {
+			return 0, err
+		}
+
+		return strings.Compare(string(ja), string(jb)), nil
+	}
+
+	return 0, nil
+}
+
+// canonical returns a key, that is equal for equal values, or false for kinds without such a key.
+func canonical(value Value) (string, bool) {
+	switch kindOf(value) {
+	case kindBool:
+		return strconv.FormatBool(value.(bool)), true
+	case kindNumber:
+		f, _ := toFloat(value)
+		if f == 0 {
+			f = 0
+		}
+
+		return strconv.FormatFloat(f, 'g', -1, 64), true
+	case kindString:
+		return value.(string), true
+	case kindTime:
+		return value.(time.Time).UTC().Format(time.RFC3339Nano), true
+	case kindInterval:
+		return strconv.FormatInt(value.(Interval).microseconds(), 10), true
+	}
+
+	return "", false
+}
+
+// coerce converts a string compared with a timestamp or an interval, like Postgres converts untyped literals.
+func coerce(a, b Value) (Value, Value, error) {
+	ka, kb := kindOf(a), kindOf(b)
+
+	if ka == kindString && (kb == kindTime || kb == kindInterval) {
+		b, a, err := coerce(b, a)
+
+		return a, b, err
+	}
+
+	if kb != kindString {
+		return a, b, nil
+	}
+
+	switch ka {
+	case kindTime:
+		t, err := timestamp(b)
+
+		return a, t, err
+	case kindInterval:
+		i, err := convert(b, "interval")
+
+		return a, i, err
+	}
+
+	return a, b, nil
+}
+
+func compareArrays(a, b []interface{}) (int, error) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		var c int
+
+		switch {
+		case a[i] == nil && b[i] == nil:
+			continue
+		case a[i] == nil:
+			c = 1
+		case b[i] == nil:
+			c = -1
+		default:
+			var err error
+
+			c, err = compare(a[i], b[i])
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		if c != 0 {
+			return c, nil
+		}
+	}
+
+	return compareNumbers(len(a), len(b)), nil
+}
+
+// compareVariables compares both variables. Null reports whether one of them is NULL.
+func compareVariables(left, right Variable, record SelectedRecord) (c int, null bool, err error) {
+	l, err := left.Variable(record)
+	if err != nil {
+		return 0, false, err
+	}
+
+	r, err := right.Variable(record)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if l == nil || r == nil {
+		return 0, true, nil
+	}
+
+	c, err = compare(l, r)
+
+	return c, false, err
+}
+
+func order(expression Variable, i, j SelectedRecord, desc, nullsLast bool) (int, error) {
+	vi, err := expression.Variable(i)
+	if err != nil {
+		return 0, err
+	}
+
+	vj, err := expression.Variable(j)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case vi == nil && vj == nil:
+		return 0, nil
+	case vi == nil && nullsLast, vj == nil && !nullsLast:
+		return 1, nil
+	case vi == nil, vj == nil:
+		return -1, nil
+	}
+
+	c, err := compare(vi, vj)
+	if desc {
+		return -c, err
+	}
+
+	return c, err
+}
+
+func comparison(left, right Variable, record GroupedRecord, test func(c int) bool) (Truth, error) {
+	c, null, err := compareVariables(left, right, record.selected())
+	if err != nil {
+		return False, err
+	}
+
+	if null {
+		return Unknown, nil
+	}
+
+	return truth(test(c)), nil
+}
+
+type Equals [2]Variable
+
+func (e Equals) Condition(record GroupedRecord) (bool, error) {
+	return holds(e, record)
+}
+
+func (e Equals) Predicate(record GroupedRecord) (Truth, error) {
+	return comparison(e[0], e[1], record, func(c int) bool { return c == 0 })
+}
+
+func (e Equals) SQL() (string, error) {
+	return renderBinary(e[0], "=", e[1])
+}
+
+type NotEquals [2]Variable
+
+func (ne NotEquals) Condition(record GroupedRecord) (bool, error) {
+	return holds(ne, record)
+}
+
+func (ne NotEquals) Predicate(record GroupedRecord) (Truth, error) {
+	return comparison(ne[0], ne[1], record, func(c int) bool { return c != 0 })
+}
+
+func (ne NotEquals) SQL() (string, error) {
+	return renderBinary(ne[0], "<>", ne[1])
+}
+
+type Greater [2]Variable
+
+func (g Greater) Condition(record GroupedRecord) (bool, error) {
+	return holds(g, record)
+}
+
+func (g Greater) Predicate(record GroupedRecord) (Truth, error) {
+	return comparison(g[0], g[1], record, func(c int) bool { return c > 0 })
+}
+
+func (g Greater) SQL() (string, error) {
+	return renderBinary(g[0], ">", g[1])
+}
+
+type GreaterOrEqual [2]Variable
+
+func (ge GreaterOrEqual) Condition(record GroupedRecord) (bool, error) {
+	return holds(ge, record)
+}
+
+func (ge GreaterOrEqual) Predicate(record GroupedRecord) (Truth, error) {
+	return comparison(ge[0], ge[1], record, func(c int) bool { return c >= 0 })
+}
+
+func (ge GreaterOrEqual) SQL() (string, error) {
+	return renderBinary(ge[0], ">=", ge[1])
+}
+
+type Less [2]Variable
+
+func (l Less) Condition(record GroupedRecord) (bool, error) {
+	return holds(l, record)
+}
+
+func (l Less) Predicate(record GroupedRecord) (Truth, error) {
+	return comparison(l[0], l[1], record, func(c int) bool { return c < 0 })
+}
+
+func (l Less) SQL() (string, error) {
+	return renderBinary(l[0], "<", l[1])
+}
+
+type LessOrEqual [2]Variable
+
+func (le LessOrEqual) Condition(record GroupedRecord) (bool, error) {
+	return holds(le, record)
+}
+
+func (le LessOrEqual) Predicate(record GroupedRecord) (Truth, error) {
+	return comparison(le[0], le[1], record, func(c int) bool { return c <= 0 })
+}
+
+func (le LessOrEqual) SQL() (string, error) {
+	return renderBinary(le[0], "<=", le[1])
+}
+
+type Between struct {
+	Expression Variable
+	Low        Variable
+	High       Variable
+}
+
+func (b Between) Condition(record GroupedRecord) (bool, error) {
+	return holds(b, record)
+}
+
+func (b Between) Predicate(record GroupedRecord) (Truth, error) {
+	low, err := comparison(b.Expression, b.Low, record, func(c int) bool { return c >= 0 })
+	if err != nil {
+		return False, err
+	}
+
+	high, err := comparison(b.Expression, b.High, record, func(c int) bool { return c <= 0 })
+	if err != nil {
+		return False, err
+	}
+
+	return low.And(high), nil
+}
+
+func (b Between) SQL() (string, error) {
+	expr, err := render(b.Expression)
+	if err != nil {
+		return "", err
+	}
+
+	bounds, err := renderBinary(b.Low, "AND", b.High)
+	if err != nil {
+		return "", err
+	}
+
+	return expr + " BETWEEN " + bounds, nil
+}
+
+type IsDistinctFrom [2]Variable
+
+func (idf IsDistinctFrom) Condition(record GroupedRecord) (bool, error) {
+	return distinct(idf[0], idf[1], record)
+}
+
+func (idf IsDistinctFrom) SQL() (string, error) {
+	return renderBinary(idf[0], "IS DISTINCT FROM", idf[1])
+}
+
+type IsNotDistinctFrom [2]Variable
+
+func (indf IsNotDistinctFrom) Condition(record GroupedRecord) (bool, error) {
+	d, err := distinct(indf[0], indf[1], record)
+
+	return !d, err
+}
+
+func (indf IsNotDistinctFrom) SQL() (string, error) {
+	return renderBinary(indf[0], "IS NOT DISTINCT FROM", indf[1])
+}
+
+type IsNull [1]Variable
+
+func (in IsNull) Condition(record GroupedRecord) (bool, error) {
+	return null(in[0], record)
+}
+
+func (in IsNull) SQL() (string, error) {
+	s, err := render(in[0])
+	if err != nil {
+		return "", err
+	}
+
+	return s + " IS NULL", nil
+}
+
+type IsNotNull [1]Variable
+
+func (inn IsNotNull) Condition(record GroupedRecord) (bool, error) {
+	n, err := null(inn[0], record)
+
+	return !n, err
+}
+
+func (inn IsNotNull) SQL() (string, error) {
+	s, err := render(inn[0])
+	if err != nil {
+		return "", err
+	}
+
+	return s + " IS NOT NULL", nil
+}
+
+// null reports whether a variable is NULL. Idents of columns without a value and nil pointers are NULL as well.
+func null(variable Variable, record GroupedRecord) (bool, error) {
+	v, err := variable.Variable(record.selected())
+	if err != nil {
+		return false, err
+	}
+
+	v, err = normalize(v)
+
+	return v == nil, err
+}
+
+// distinct compares like Equals, but treats NULL as a comparable value.
+func distinct(left, right Variable, record GroupedRecord) (bool, error) {
+	l, err := left.Variable(record.selected())
+	if err != nil {
+		return false, err
+	}
+
+	r, err := right.Variable(record.selected())
+	if err != nil {
+		return false, err
+	}
+
+	if l == nil || r == nil {
+		return (l == nil) != (r == nil), nil
+	}
+
+	c, err := compare(l, r)
+
+	return c != 0, err
+}
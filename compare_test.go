@@ -0,0 +1,133 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestComparison(t *testing.T) {
+	record := querify.GroupedRecord{Source: querify.Record{
+		Columns: []string{"int", "float", "text", "null", "absent"},
+		Values:  []querify.Value{2, 2.0, "b", nil},
+	}}
+
+	i, f, s, n := querify.Ident("int"), querify.Ident("float"), querify.Ident("text"), querify.Ident("null")
+	one, three, a := querify.Literal{Value: 1}, querify.Literal{Value: 3.5}, querify.Literal{Value: "a"}
+
+	tests := []struct {
+		condition querify.Condition
+		want      bool
+	}{
+		{querify.Equals{i, f}, true},
+		{querify.NotEquals{i, f}, false},
+		{querify.Greater{i, one}, true},
+		{querify.GreaterOrEqual{i, f}, true},
+		{querify.Less{s, a}, false},
+		{querify.LessOrEqual{a, s}, true},
+		{querify.Between{Expression: i, Low: one, High: three}, true},
+		{querify.Between{Expression: s, Low: a, High: a}, false},
+		{querify.Equals{n, n}, false},
+		{querify.NotEquals{n, one}, false},
+		{querify.Between{Expression: i, Low: n, High: three}, false},
+		{querify.IsDistinctFrom{n, n}, false},
+		{querify.IsDistinctFrom{n, one}, true},
+		{querify.IsDistinctFrom{i, f}, false},
+		{querify.IsNotDistinctFrom{n, n}, true},
+		{querify.IsNull{n}, true},
+		{querify.IsNull{querify.Ident("absent")}, true},
+		{querify.IsNull{querify.Literal{Value: (*int)(nil)}}, true},
+		{querify.IsNull{i}, false},
+		{querify.IsNotNull{s}, true},
+		{querify.IsNotNull{querify.Ident("absent")}, false},
+	}
+
+	for _, test := range tests {
+		got, err := test.condition.Condition(record)
+		if err != nil {
+			t.Fatal(test.condition, err)
+		}
+
+		if got != test.want {
+			t.Fatal(test.condition, got)
+		}
+	}
+
+	for _, c := range []querify.Condition{querify.Equals{i, s}, querify.Greater{s, one}, querify.IsDistinctFrom{s, i}} {
+		if _, err := c.Condition(record); err == nil {
+			t.Fatal(c)
+		}
+	}
+}
+
+func TestOrderByKeys(t *testing.T) {
+	var rows []map[string]interface{}
+
+	err := querify.From([]map[string]interface{}{
+		{"a": 1, "b": 1},
+		{"a": 2, "b": 2},
+		{"a": 1, "b": 3},
+		{"a": nil, "b": 4},
+	}).Select(querify.Ident("a"), querify.Ident("b")).
+		OrderBy(querify.Asc{Expression: querify.Ident("a"), NullsLast: true}, querify.Desc{Expression: querify.Ident("b")}).
+		Scan(&rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b []float64
+	for _, r := range rows {
+		b = append(b, r["b"].(float64))
+	}
+
+	if !reflect.DeepEqual(b, []float64{3, 1, 2, 4}) {
+		t.Fatal(b)
+	}
+}
+
+func TestParseComparison(t *testing.T) {
+	stmt, err := querify.Parse(`SELECT name FROM users WHERE id BETWEEN 2 AND 3 AND name <> 'Tom' `+
+		`AND id >= 1 AND id <= 3 AND name IS DISTINCT FROM NULL`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"Alex"}) {
+		t.Fatal(names)
+	}
+
+	roundTrip(t, stmt, tables())
+}
+
+func TestParseIsNull(t *testing.T) {
+	data := tables()
+	data["users"] = querify.From([]map[string]interface{}{
+		{"id": 1, "name": "Max"},
+		{"id": 4, "name": "Anna"},
+	})
+
+	stmt, err := querify.Parse(`SELECT u.name FROM users u LEFT JOIN user_hobbies uh ON u.id = uh.user_id `+
+		`WHERE uh.user_id IS NULL AND u.name IS NOT NULL`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"Anna"}) {
+		t.Fatal(names)
+	}
+
+	roundTrip(t, stmt, data)
+}
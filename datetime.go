@@ -0,0 +1,552 @@
+package querify
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var clock = time.Now
+
+// SetClock replaces the clock of Now and Age and returns a function that restores it.
+func SetClock(now func() time.Time) (restore func()) {
+	previous := clock
+	clock = now
+
+	return func() { clock = previous }
+}
+
+// Interval is a Postgres interval. Months and days are kept apart from the duration, because their length varies.
+type Interval struct {
+	Months   int
+	Days     int
+	Duration time.Duration
+}
+
+func (i Interval) String() string {
+	var parts []string
+
+	unit := func(n int, singular, plural string) {
+		switch n {
+		case 0:
+		case 1:
+			parts = append(parts, strconv.Itoa(n)+" "+singular)
+		default:
+			parts = append(parts, strconv.Itoa(n)+" "+plural)
+		}
+	}
+
+	unit(i.Months/12, "year", "years")
+	unit(i.Months%12, "mon", "mons")
+	unit(i.Days, "day", "days")
+
+	if i.Duration != 0 || len(parts) == 0 {
+		d, sign := i.Duration, ""
+		if d < 0 {
+			d, sign = -d, "-"
+		}
+
+		s := fmt.Sprintf("%s%02d:%02d:%02d", sign, d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second)
+
+		if frac := d % time.Second / time.Microsecond; frac != 0 {
+			s += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+		}
+
+		parts = append(parts, s)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (i Interval) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(i.String())), nil
+}
+
+// microseconds returns the length of the interval with months of 30 days, like Postgres compares intervals.
+func (i Interval) microseconds() int64 {
+	return (int64(i.Months)*30+int64(i.Days))*int64(24*time.Hour/time.Microsecond) + int64(i.Duration/time.Microsecond)
+}
+
+func (i Interval) scale(f float64) Interval {
+	months := float64(i.Months) * f
+	days := float64(i.Days)*f + (months-math.Trunc(months))*30
+	duration := float64(i.Duration)*f + (days-math.Trunc(days))*float64(24*time.Hour)
+
+	return Interval{Months: int(months), Days: int(days), Duration: time.Duration(math.Round(duration))}
+}
+
+func (i Interval) add(j Interval) Interval {
+	return Interval{Months: i.Months + j.Months, Days: i.Days + j.Days, Duration: i.Duration + j.Duration}
+}
+
+// addTo adds the months like Postgres, so that the day is at most the last day of the month.
+func (i Interval) addTo(t time.Time) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(i.Months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+
+	return first.AddDate(0, 0, day-1+i.Days).Add(i.Duration)
+}
+
+var intervalUnits = map[string]Interval{
+	"microsecond": {Duration: time.Microsecond},
+	"millisecond": {Duration: time.Millisecond},
+	"second":      {Duration: time.Second},
+	"minute":      {Duration: time.Minute},
+	"hour":        {Duration: time.Hour},
+	"day":         {Days: 1},
+	"week":        {Days: 7},
+	"month":       {Months: 1},
+	"year":        {Months: 12},
+	"decade":      {Months: 120},
+	"century":     {Months: 1200},
+	"millennium":  {Months: 12000},
+}
+
+var intervalAliases = map[string]string{
+	"us": "microsecond", "usec": "microsecond", "usecs": "microsecond", "microseconds": "microsecond",
+	"ms": "millisecond", "msec": "millisecond", "msecs": "millisecond", "milliseconds": "millisecond",
+	"s": "second", "sec": "second", "secs": "second", "seconds": "second",
+	"m": "minute", "min": "minute", "mins": "minute", "minutes": "minute",
+	"h": "hour", "hr": "hour", "hrs": "hour", "hours": "hour",
+	"d": "day", "days": "day",
+	"w": "week", "weeks": "week",
+	"mon": "month", "mons": "month", "months": "month",
+	"y": "year", "yr": "year", "yrs": "year", "years": "year",
+	"decades": "decade", "centuries": "century", "millennia": "millennium", "millenniums": "millennium",
+}
+
+// parseInterval parses intervals like '1 year 2 months', '3 days 04:05:06' or '2 hours ago'.
+func parseInterval(s string) (Interval, error) {
+	fields := strings.Fields(strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "@")))
+	if len(fields) == 0 {
+		return Interval{}, fmt.Errorf("invalid interval")
+	}
+
+	var out Interval
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		if field == "ago" && i == len(fields)-1 {
+			return out.scale(-1), nil
+		}
+
+		if strings.Contains(field, ":") {
+			d, err := parseClock(field)
+			if err != nil {
+				return Interval{}, err
+			}
+
+			out.Duration += d
+
+			continue
+		}
+
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil || i+1 == len(fields) {
+			return Interval{}, fmt.Errorf("invalid interval")
+		}
+
+		i++
+
+		name := fields[i]
+		if alias, ok := intervalAliases[name]; ok {
+			name = alias
+		}
+
+		unit, ok := intervalUnits[name]
+		if !ok {
+			return Interval{}, fmt.Errorf("invalid interval unit '%s'", fields[i])
+		}
+
+		out = out.add(unit.scale(n))
+	}
+
+	return out, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+
+	if strings.HasPrefix(s, "-") {
+		s, sign = s[1:], -1
+	}
+
+	parts := strings.Split(strings.TrimPrefix(s, "+"), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid interval")
+	}
+
+	var d time.Duration
+
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second}[:len(parts)] {
+		n, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid interval")
+		}
+
+		d += time.Duration(math.Round(n * float64(unit)))
+	}
+
+	return sign * d, nil
+}
+
+func castInterval(value Value) (Value, error) {
+	switch v := value.(type) {
+	case Interval:
+		return v, nil
+	case string:
+		return parseInterval(v)
+	}
+
+	return nil, fmt.Errorf("unsupported type '%s'", kindOf(value))
+}
+
+// timestamp converts strings to timestamps, so that sources with RFC 3339 strings work without a cast.
+func timestamp(value Value) (time.Time, error) {
+	t, err := castTimestamp(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("querify: cannot cast '%v' to timestamp: %w", value, err)
+	}
+
+	return t.(time.Time), nil
+}
+
+// temporal computes timestamp and interval arithmetic like Postgres.
+func temporal(l, r Value, op string) (Value, error) {
+	kl, kr := kindOf(l), kindOf(r)
+
+	switch {
+	case kl == kindString && kr == kindInterval:
+		t, err := timestamp(l)
+		if err != nil {
+			return nil, err
+		}
+
+		return temporal(t, r, op)
+	case kl == kindTime && kr == kindString && op == "-":
+		t, err := timestamp(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return temporal(l, t, op)
+	}
+
+	switch {
+	case kl == kindTime && kr == kindInterval && op == "+":
+		return r.(Interval).addTo(l.(time.Time)), nil
+	case kl == kindInterval && kr == kindTime && op == "+":
+		return l.(Interval).addTo(r.(time.Time)), nil
+	case kl == kindTime && kr == kindInterval && op == "-":
+		return r.(Interval).scale(-1).addTo(l.(time.Time)), nil
+	case kl == kindTime && kr == kindTime && op == "-":
+		d := l.(time.Time).Sub(r.(time.Time))
+
+		return Interval{Days: int(d / (24 * time.Hour)), Duration: d % (24 * time.Hour)}, nil
+	case kl == kindInterval && kr == kindInterval && op == "+":
+		return l.(Interval).add(r.(Interval)), nil
+	case kl == kindInterval && kr == kindInterval && op == "-":
+		return l.(Interval).add(r.(Interval).scale(-1)), nil
+	case kl == kindInterval && kr == kindNumber && (op == "*" || op == "/"):
+		f, _ := toFloat(r)
+
+		if op == "*" {
+			return l.(Interval).scale(f), nil
+		}
+
+		if f == 0 {
+			return nil, fmt.Errorf("querify: division by zero")
+		}
+
+		return l.(Interval).scale(1 / f), nil
+	case kl == kindNumber && kr == kindInterval && op == "*":
+		f, _ := toFloat(l)
+
+		return r.(Interval).scale(f), nil
+	}
+
+	return nil, fmt.Errorf("querify: cannot apply '%s' to types '%s' and '%s'", op, kl, kr)
+}
+
+type Now struct{}
+
+func (Now) Variable(record SelectedRecord) (Value, error) {
+	return current(record), nil
+}
+
+func (n Now) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("now", n, table)
+}
+
+func (Now) SQL() (string, error) {
+	return "now()", nil
+}
+
+// current returns the time of the execution of the statement, like the start of a transaction in Postgres.
+func current(record SelectedRecord) time.Time {
+	if record.now.IsZero() {
+		return clock()
+	}
+
+	return record.now
+}
+
+// DateTrunc truncates a timestamp to the precision of Field, like 'hour', 'day', 'week', 'month' or 'year'.
+type DateTrunc struct {
+	Field      string
+	Expression Variable
+}
+
+func (dt DateTrunc) Variable(record SelectedRecord) (Value, error) {
+	value, err := dt.Expression.Variable(record)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	t, err := timestamp(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return truncate(t, strings.ToLower(dt.Field))
+}
+
+func (dt DateTrunc) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("date_trunc", dt, table)
+}
+
+func (dt DateTrunc) SQL() (string, error) {
+	return renderCall("date_trunc", Literal{Value: dt.Field}, dt.Expression)
+}
+
+func truncate(t time.Time, field string) (time.Time, error) {
+	year, month, day := t.Date()
+	loc := t.Location()
+
+	switch field {
+	case "microsecond", "microseconds":
+		return t.Add(-time.Duration(t.Nanosecond() % 1000)), nil
+	case "millisecond", "milliseconds":
+		return t.Add(-time.Duration(t.Nanosecond() % 1000000)), nil
+	case "second":
+		return t.Add(-time.Duration(t.Nanosecond())), nil
+	case "minute":
+		return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, loc), nil
+	case "hour":
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(year, month, day, 0, 0, 0, 0, loc), nil
+	case "week":
+		return time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc), nil
+	case "quarter":
+		return time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, loc), nil
+	case "year":
+		return time.Date(year, 1, 1, 0, 0, 0, 0, loc), nil
+	case "decade":
+		return time.Date(year-year%10, 1, 1, 0, 0, 0, 0, loc), nil
+	case "century":
+		return time.Date((year-1)/100*100+1, 1, 1, 0, 0, 0, 0, loc), nil
+	case "millennium":
+		return time.Date((year-1)/1000*1000+1, 1, 1, 0, 0, 0, 0, loc), nil
+	}
+
+	return time.Time{}, fmt.Errorf("querify: unit '%s' not recognized", field)
+}
+
+// Extract returns a field of a timestamp or an interval as float64, like 'year', 'month', 'dow' or 'epoch'.
+type Extract struct {
+	Field      string
+	Expression Variable
+}
+
+func (e Extract) Variable(record SelectedRecord) (Value, error) {
+	value, err := e.Expression.Variable(record)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	field := strings.ToLower(e.Field)
+
+	if i, ok := value.(Interval); ok {
+		return extractInterval(i, field)
+	}
+
+	t, err := timestamp(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractTimestamp(t, field)
+}
+
+func (e Extract) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("extract", e, table)
+}
+
+func (e Extract) SQL() (string, error) {
+	s, err := render(e.Expression)
+	if err != nil {
+		return "", err
+	}
+
+	return "EXTRACT(" + strings.ToUpper(e.Field) + " FROM " + s + ")", nil
+}
+
+func extractTimestamp(t time.Time, field string) (Value, error) {
+	seconds := float64(t.Second()) + float64(t.Nanosecond())/1e9
+	year, week := t.ISOWeek()
+
+	switch field {
+	case "microseconds":
+		return math.Round(seconds * 1e6), nil
+	case "milliseconds":
+		return seconds * 1e3, nil
+	case "second":
+		return seconds, nil
+	case "minute":
+		return float64(t.Minute()), nil
+	case "hour":
+		return float64(t.Hour()), nil
+	case "day":
+		return float64(t.Day()), nil
+	case "dow":
+		return float64(t.Weekday()), nil
+	case "isodow":
+		return float64((int(t.Weekday())+6)%7 + 1), nil
+	case "doy":
+		return float64(t.YearDay()), nil
+	case "week":
+		return float64(week), nil
+	case "isoyear":
+		return float64(year), nil
+	case "month":
+		return float64(t.Month()), nil
+	case "quarter":
+		return float64((t.Month()-1)/3 + 1), nil
+	case "year":
+		return float64(t.Year()), nil
+	case "decade":
+		return float64(t.Year() / 10), nil
+	case "century":
+		return float64((t.Year() + 99) / 100), nil
+	case "millennium":
+		return float64((t.Year() + 999) / 1000), nil
+	case "epoch":
+		return float64(t.UnixNano()) / 1e9, nil
+	case "timezone":
+		_, offset := t.Zone()
+
+		return float64(offset), nil
+	}
+
+	return nil, fmt.Errorf("querify: unit '%s' not recognized", field)
+}
+
+func extractInterval(i Interval, field string) (Value, error) {
+	seconds := float64(i.Duration%time.Minute) / float64(time.Second)
+
+	switch field {
+	case "microseconds":
+		return math.Round(seconds * 1e6), nil
+	case "milliseconds":
+		return seconds * 1e3, nil
+	case "second":
+		return seconds, nil
+	case "minute":
+		return float64(i.Duration % time.Hour / time.Minute), nil
+	case "hour":
+		return float64(i.Duration / time.Hour), nil
+	case "day":
+		return float64(i.Days), nil
+	case "month":
+		return float64(i.Months % 12), nil
+	case "quarter":
+		return float64(i.Months%12/3 + 1), nil
+	case "year":
+		return float64(i.Months / 12), nil
+	case "epoch":
+		return float64(i.Months/12)*365.25*86400 + float64(i.Months%12)*30*86400 + float64(i.Days)*86400 +
+			i.Duration.Seconds(), nil
+	}
+
+	return nil, fmt.Errorf("querify: unit '%s' not recognized for interval", field)
+}
+
+// Age returns the interval between From and To, or midnight of the current day without To.
+type Age struct {
+	From Variable
+	To   Variable
+}
+
+func (a Age) Variable(record SelectedRecord) (Value, error) {
+	from, err := a.From.Variable(record)
+	if err != nil || from == nil {
+		return nil, err
+	}
+
+	start, err := timestamp(from)
+	if err != nil {
+		return nil, err
+	}
+
+	now := current(record)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if a.To != nil {
+		to, err := a.To.Variable(record)
+		if err != nil || to == nil {
+			return nil, err
+		}
+
+		if end, err = timestamp(to); err != nil {
+			return nil, err
+		}
+	}
+
+	return age(end, start), nil
+}
+
+func (a Age) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("age", a, table)
+}
+
+func (a Age) SQL() (string, error) {
+	if a.To == nil {
+		return renderCall("age", a.From)
+	}
+
+	return renderCall("age", a.To, a.From)
+}
+
+// age subtracts the fields of start from end and borrows days from the month of start, like Postgres.
+func age(end, start time.Time) Interval {
+	if end.Before(start) {
+		return age(start, end).scale(-1)
+	}
+
+	months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month())
+	days := end.Day() - start.Day()
+	clock := time.Duration(end.Hour()-start.Hour())*time.Hour + time.Duration(end.Minute()-start.Minute())*time.Minute +
+		time.Duration(end.Second()-start.Second())*time.Second + time.Duration(end.Nanosecond()-start.Nanosecond())
+
+	if clock < 0 {
+		clock += 24 * time.Hour
+		days--
+	}
+
+	if days < 0 {
+		days += time.Date(start.Year(), start.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		months--
+	}
+
+	return Interval{Months: months, Days: days, Duration: clock}
+}
@@ -0,0 +1,242 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wroge/querify"
+)
+
+func TestInterval(t *testing.T) {
+	tests := map[string]string{
+		"1 year 2 months 3 days 04:05:06.5": "1 year 2 mons 3 days 04:05:06.5",
+		"7 days":                            "7 days",
+		"1.5 days":                          "1 day 12:00:00",
+		"2 hours 30 min ago":                "-02:30:00",
+		"@ 1 week":                          "7 days",
+		"0 seconds":                         "00:00:00",
+	}
+
+	for in, want := range tests {
+		got, err := querify.Cast{Expression: querify.Literal{Value: in}, Type: "interval"}.Variable(querify.SelectedRecord{})
+		if err != nil {
+			t.Fatal(in, err)
+		}
+
+		if got.(querify.Interval).String() != want {
+			t.Fatal(in, got)
+		}
+	}
+
+	if _, err := (querify.Cast{Expression: querify.Literal{Value: "3 fortnights"}, Type: "interval"}).
+		Variable(querify.SelectedRecord{}); err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDateTime(t *testing.T) {
+	ts := time.Date(2021, 3, 31, 10, 30, 15, 0, time.UTC)
+	month := querify.Interval{Months: 1}
+
+	record := querify.SelectedRecord{Source: querify.Record{
+		Columns: []string{"ts", "text", "null"},
+		Values:  []querify.Value{ts, "2021-03-31T10:30:15Z", nil},
+	}}
+
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+	tsIdent, text := querify.Ident("ts"), querify.Ident("text")
+
+	defer querify.SetClock(func() time.Time { return time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC) })()
+
+	tests := []struct {
+		variable querify.Variable
+		want     querify.Value
+	}{
+		{querify.Add{tsIdent, lit(month)}, time.Date(2021, 4, 30, 10, 30, 15, 0, time.UTC)},
+		{querify.Add{lit("2024-01-31"), lit(month)}, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{querify.Add{lit("2023-01-31"), lit(month)}, time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{querify.Add{lit("2024-02-29"), lit(querify.Interval{Months: 12})}, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{
+			querify.Add{lit("2024-01-31"), lit(querify.Interval{Months: 1, Days: 1})},
+			time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{querify.Sub{lit("2024-03-31"), lit(month)}, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{querify.Add{text, lit(querify.Interval{Days: 1})}, time.Date(2021, 4, 1, 10, 30, 15, 0, time.UTC)},
+		{querify.Sub{tsIdent, lit(querify.Interval{Duration: time.Hour})}, time.Date(2021, 3, 31, 9, 30, 15, 0, time.UTC)},
+		{querify.Sub{tsIdent, lit(time.Date(2021, 3, 30, 0, 0, 0, 0, time.UTC))},
+			querify.Interval{Days: 1, Duration: 10*time.Hour + 30*time.Minute + 15*time.Second}},
+		{querify.Mul{lit(month), lit(1.5)}, querify.Interval{Months: 1, Days: 15}},
+		{querify.Neg{lit(month)}, querify.Interval{Months: -1}},
+		{querify.Add{tsIdent, querify.Ident("null")}, nil},
+		{querify.DateTrunc{Field: "month", Expression: tsIdent}, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{querify.DateTrunc{Field: "week", Expression: text}, time.Date(2021, 3, 29, 0, 0, 0, 0, time.UTC)},
+		{querify.DateTrunc{Field: "quarter", Expression: tsIdent}, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{
+			querify.DateTrunc{
+				Field: "day", Expression: querify.Cast{Expression: lit("2024-01-01 01:00+02"), Type: "timestamptz"},
+			},
+			time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			querify.DateTrunc{Field: "millisecond", Expression: lit(ts.Add(1234567))},
+			time.Date(2021, 3, 31, 10, 30, 15, 1000000, time.UTC),
+		},
+		{
+			querify.DateTrunc{Field: "microsecond", Expression: lit(ts.Add(1234567))},
+			time.Date(2021, 3, 31, 10, 30, 15, 1234000, time.UTC),
+		},
+		{querify.Extract{Field: "year", Expression: tsIdent}, 2021.0},
+		{querify.Extract{Field: "dow", Expression: text}, 3.0},
+		{querify.Extract{Field: "epoch", Expression: lit(querify.Interval{Days: 1})}, 86400.0},
+		{querify.Extract{Field: "hour", Expression: querify.Ident("null")}, nil},
+		{querify.Age{From: lit("1957-06-13"), To: lit("2001-04-10")}, querify.Interval{Months: 43*12 + 9, Days: 27}},
+		{querify.Age{From: lit("2024-01-31"), To: lit("2024-03-01")}, querify.Interval{Months: 1, Days: 1}},
+		{
+			querify.Age{From: tsIdent},
+			querify.Interval{Months: 14, Days: 14, Duration: 13*time.Hour + 29*time.Minute + 45*time.Second},
+		},
+		{querify.Now{}, time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Variable(record)
+		if err != nil {
+			t.Fatal(test.variable, err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%v: %#v", test.variable, got)
+		}
+	}
+
+	conditions := []querify.Condition{
+		querify.Greater{tsIdent, lit("2021-01-01")},
+		querify.Less{lit("2021-01-01"), tsIdent},
+		querify.Equals{lit(querify.Interval{Months: 1}), lit(querify.Interval{Days: 30})},
+		querify.Greater{lit(querify.Interval{Days: 1}), lit("23 hours")},
+	}
+
+	for _, c := range conditions {
+		ok, err := c.Condition(querify.GroupedRecord{Source: record.Source})
+		if err != nil || !ok {
+			t.Fatal(c, ok, err)
+		}
+	}
+
+	for _, v := range []querify.Variable{
+		querify.Add{tsIdent, tsIdent},
+		querify.DateTrunc{Field: "fortnight", Expression: tsIdent},
+		querify.Extract{Field: "dow", Expression: lit(month)},
+		querify.Extract{Field: "year", Expression: lit("yesterday")},
+	} {
+		if _, err := v.Variable(record); err == nil {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	defer querify.SetClock(func() time.Time { return time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC) })()
+
+	data := map[string]querify.Query{
+		"orders": querify.From([]map[string]interface{}{
+			{"id": 1, "created": "2021-01-15T10:00:00Z"},
+			{"id": 2, "created": "2021-01-20T10:00:00Z"},
+			{"id": 3, "created": "2021-02-01T00:00:00Z"},
+			{"id": 4, "created": "2021-03-05T08:00:00Z"},
+		}),
+	}
+
+	stmt, err := querify.Parse(`SELECT month, count(*) AS orders, number FROM (`+
+		`SELECT date_trunc('month', created) AS month, EXTRACT(MONTH FROM created::timestamp) AS number FROM orders `+
+		`WHERE created::timestamp > DATE '2021-01-01') AS o GROUP BY month, number ORDER BY month`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []struct {
+		Month  time.Time
+		Orders int
+		Number int
+	}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	march := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	if len(out) != 3 || out[0].Orders != 2 || out[0].Number != 1 || !out[2].Month.Equal(march) {
+		t.Fatal(out)
+	}
+
+	stmt, err = querify.Parse(`SELECT id FROM orders WHERE created::timestamp >= now() - INTERVAL '7 days' `+
+		`OR created::timestamp + '1 month'::interval < current_date - interval '15 days'`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+
+	if err = stmt.Execute().ScanColumn("id", &ids); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ids, []int{1, 2, 4}) {
+		t.Fatal(ids)
+	}
+
+	roundTrip(t, stmt, data)
+
+	if _, err = querify.Parse(`SELECT id FROM orders WHERE created > DATE 'yesterday'`, data); err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseNow(t *testing.T) {
+	start := time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)
+	calls := 0
+
+	defer querify.SetClock(func() time.Time {
+		calls++
+
+		return start.Add(time.Duration(calls) * time.Second)
+	})()
+
+	data := map[string]querify.Query{
+		"orders": querify.From([]map[string]interface{}{{"id": 1}, {"id": 2}, {"id": 3}}),
+	}
+
+	stmt, err := querify.Parse(`SELECT id, now() AS a, now() AS b FROM orders WHERE now() = now() `+
+		`AND EXISTS (SELECT * FROM orders o WHERE o.id = orders.id AND now() = now()) ORDER BY now(), id`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		var out []struct {
+			ID int
+			A  time.Time
+			B  time.Time
+		}
+
+		if err = stmt.Scan(&out); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(out) != 3 {
+			t.Fatal(out)
+		}
+
+		for _, o := range out {
+			if !o.A.Equal(out[0].A) || !o.B.Equal(out[0].A) {
+				t.Fatal(out)
+			}
+		}
+
+		if calls != run {
+			t.Fatal(calls)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package querify
+
+// CachedRegexps returns the number of cached regular expressions.
+func CachedRegexps() int {
+	regexps.mu.Lock()
+	defer regexps.mu.Unlock()
+
+	return regexps.order.Len()
+}
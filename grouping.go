@@ -0,0 +1,78 @@
+package querify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rollup groups by all columns and every prefix of them, ROLLUP (a, b) is GROUPING SETS ((a, b), (a), ()).
+type Rollup []string
+
+func (r Rollup) GroupBy() (GroupingSets, error) {
+	sets := make(GroupingSets, 0, len(r)+1)
+
+	for i := len(r); i >= 0; i-- {
+		sets = append(sets, append([]string{}, r[:i]...))
+	}
+
+	return sets, nil
+}
+
+func (r Rollup) SQL() (string, error) {
+	set, err := GroupingSets{r}.SQL()
+	if err != nil {
+		return "", err
+	}
+
+	return "ROLLUP " + set, nil
+}
+
+// Grouping returns a bit mask of the columns, that aren't grouped in the current grouping set.
+type Grouping []string
+
+func (g Grouping) Variable(record SelectedRecord) (Value, error) {
+	set := record.Grouped.grouping
+	if set == nil {
+		return nil, fmt.Errorf("querify: GROUPING requires GROUP BY")
+	}
+
+	var mask int64
+
+	for _, column := range g {
+		if !grouped(record.Grouped.groupBy, column) {
+			return nil, fmt.Errorf("querify: arguments to GROUPING must be grouping expressions, got '%s'", column)
+		}
+
+		mask <<= 1
+
+		if !grouped(set, column) {
+			mask |= 1
+		}
+	}
+
+	return mask, nil
+}
+
+func (g Grouping) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("grouping", g, table)
+}
+
+func (g Grouping) SQL() (string, error) {
+	columns := make([]string, len(g))
+
+	for i, c := range g {
+		columns[i], _ = Ident(c).SQL()
+	}
+
+	return "grouping(" + strings.Join(columns, ", ") + ")", nil
+}
+
+func grouped(set []string, column string) bool {
+	for _, c := range set {
+		if Ident(column).match(c) || Ident(c).match(column) {
+			return true
+		}
+	}
+
+	return false
+}
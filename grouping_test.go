@@ -0,0 +1,107 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func sales() querify.Table {
+	return querify.From([]map[string]interface{}{
+		{"region": "east", "city": "A", "amount": 10},
+		{"region": "east", "city": "B", "amount": 20},
+		{"region": "west", "city": "C", "amount": 5},
+		{"region": "west", "city": nil, "amount": 7},
+	})
+}
+
+func TestGrouping(t *testing.T) {
+	sets, err := querify.Rollup{"a", "b"}.GroupBy()
+	if err != nil || !reflect.DeepEqual(sets, querify.GroupingSets{{"a", "b"}, {"a"}, {}}) {
+		t.Fatal(sets, err)
+	}
+
+	sql, err := querify.Rollup{"a", "b"}.SQL()
+	if err != nil || sql != "ROLLUP (a, b)" {
+		t.Fatal(sql, err)
+	}
+
+	var rows []map[string]interface{}
+
+	err = sales().GroupBy(querify.Rollup{"region", "city"}).Select(
+		querify.Ident("region"),
+		querify.Ident("city"),
+		querify.As{Name: "level", Expression: querify.Grouping{"region", "city"}},
+		querify.As{Name: "total", Expression: querify.Sum{Expression: querify.Ident("amount")}},
+	).Scan(&rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"region": "east", "city": "A", "level": 0.0, "total": 10.0},
+		{"region": "east", "city": "B", "level": 0.0, "total": 20.0},
+		{"region": "west", "city": "C", "level": 0.0, "total": 5.0},
+		{"region": "west", "city": nil, "level": 0.0, "total": 7.0},
+		{"region": "east", "city": nil, "level": 1.0, "total": 30.0},
+		{"region": "west", "city": nil, "level": 1.0, "total": 12.0},
+		{"region": nil, "city": nil, "level": 3.0, "total": 42.0},
+	}
+
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatal(rows)
+	}
+
+	err = sales().Select(querify.Ident("region"), querify.Grouping{"region"}).Scan(&rows)
+	if err == nil {
+		t.Fatal(rows)
+	}
+
+	err = sales().GroupBy(querify.Rollup{"region"}).Select(querify.Grouping{"city"}).Scan(&rows)
+	if err == nil {
+		t.Fatal(rows)
+	}
+}
+
+func TestParseGrouping(t *testing.T) {
+	data := tables()
+	data["sales"] = sales()
+
+	stmt, err := querify.Parse(`SELECT region, city, grouping(region, city) AS level, sum(amount) AS total `+
+		`FROM sales GROUP BY ROLLUP (region, city) HAVING grouping(city) = 1 ORDER BY level, region`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"region": "east", "city": nil, "level": 1.0, "total": 30.0},
+		{"region": "west", "city": nil, "level": 1.0, "total": 12.0},
+		{"region": nil, "city": nil, "level": 3.0, "total": 42.0},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, data)
+
+	if _, err = querify.Parse(`SELECT grouping(amount + 1) FROM sales GROUP BY amount`, data); err == nil {
+		t.Fatal(err)
+	}
+
+	stmt, err = querify.Parse(`SELECT region, grouping(city) FROM sales GROUP BY GROUPING SETS ((region), ())`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = stmt.Scan(&out); err == nil {
+		t.Fatal(out)
+	}
+}
@@ -27,3 +27,15 @@ type Variable interface {
 type OrderBy interface {
 	OrderBy(i, j SelectedRecord) (int, error)
 }
+
+type SQL interface {
+	SQL() (string, error)
+}
+
+type Predicate interface {
+	Predicate(record GroupedRecord) (Truth, error)
+}
+
+type Window interface {
+	Window(record WindowRecord) (Value, error)
+}
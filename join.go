@@ -0,0 +1,327 @@
+package querify
+
+import (
+	"encoding/json"
+)
+
+func joinRow(left []Value, leftColumns int, right []Value, rightColumns int) []Value {
+	row := make([]Value, leftColumns+rightColumns)
+
+	copy(row[:leftColumns], left)
+	copy(row[leftColumns:], right)
+
+	return row
+}
+
+func join(left, right Query, on Condition, outerLeft, outerRight bool) Table {
+	l := left.Query()
+	if l.Err != nil {
+		return Table{Err: l.Err}
+	}
+
+	r := right.Query()
+	if r.Err != nil {
+		return Table{Err: r.Err}
+	}
+
+	columns := append([]string{}, l.Columns...)
+	columns = append(columns, r.Columns...)
+	data := make([][]Value, 0, len(l.Data))
+	matched := make([]bool, len(r.Data))
+
+	all := make([]int, len(r.Data))
+	for k := range all {
+		all[k] = k
+	}
+
+	plan, hashed := planHashJoin(on, l.Columns, r.Columns)
+
+	if on != nil {
+		on = prepare(on)
+	}
+
+	var (
+		keys    []string
+		buckets map[string][]int
+	)
+
+	if hashed {
+		var err error
+
+		keys, buckets, hashed, err = plan.build(l.Data, r.Data)
+		if err != nil {
+			return Table{Err: err}
+		}
+	}
+
+	for i, dl := range l.Data {
+		found := false
+		candidates := all
+
+		if hashed {
+			candidates = buckets[keys[i]]
+		}
+
+		for _, k := range candidates {
+			row := joinRow(dl, len(l.Columns), r.Data[k], len(r.Columns))
+
+			if on != nil {
+				ok, err := on.Condition(GroupedRecord{Source: Record{Columns: columns, Values: row}})
+				if err != nil {
+					return Table{Err: err}
+				}
+
+				if !ok {
+					continue
+				}
+			}
+
+			found = true
+			matched[k] = true
+			data = append(data, row)
+		}
+
+		if !found && outerLeft {
+			data = append(data, joinRow(dl, len(l.Columns), nil, len(r.Columns)))
+		}
+	}
+
+	if outerRight {
+		for k, dr := range r.Data {
+			if !matched[k] {
+				data = append(data, joinRow(nil, len(l.Columns), dr, len(r.Columns)))
+			}
+		}
+	}
+
+	return Table{
+		Columns: columns,
+		Data:    data,
+	}
+}
+
+// hashJoin joins the columns left[i] and right[i] by hashing the right rows.
+type hashJoin struct {
+	left  []int
+	right []int
+}
+
+func planHashJoin(on Condition, left, right []string) (hashJoin, bool) {
+	var conditions And
+
+	if c, ok := on.(correlated); ok {
+		on = c.condition
+	}
+
+	switch c := on.(type) {
+	case Equals:
+		conditions = And{c}
+	case And:
+		conditions = c
+	default:
+		return hashJoin{}, false
+	}
+
+	var plan hashJoin
+
+	for _, c := range conditions {
+		if e, ok := c.(Equals); ok {
+			if l, r, ok := equiColumns(e, left, right); ok {
+				plan.left = append(plan.left, l)
+				plan.right = append(plan.right, r)
+			}
+		}
+	}
+
+	return plan, len(plan.left) > 0
+}
+
+// build returns the keys of the left rows and the buckets of the right rows, or false if a key can't be hashed.
+func (h hashJoin) build(left, right [][]Value) ([]string, map[string][]int, bool, error) {
+	kinds := make([]kind, len(h.left))
+
+	keys := make([]string, len(left))
+
+	for i, d := range left {
+		key, ok, err := hashKey(d, h.left, kinds)
+		if err != nil || !ok {
+			return nil, nil, false, err
+		}
+
+		keys[i] = key
+	}
+
+	buckets := map[string][]int{}
+
+	for k, d := range right {
+		key, ok, err := hashKey(d, h.right, kinds)
+		if err != nil || !ok {
+			return nil, nil, false, err
+		}
+
+		if key != "" {
+			buckets[key] = append(buckets[key], k)
+		}
+	}
+
+	return keys, buckets, true, nil
+}
+
+// hashKey returns an empty key for NULL values, which are never equal.
+func hashKey(row []Value, columns []int, kinds []kind) (string, bool, error) {
+	values := make([]string, len(columns))
+	null := false
+
+	for i, c := range columns {
+		if c >= len(row) || row[c] == nil {
+			null = true
+
+			continue
+		}
+
+		value, err := normalize(row[c])
+		if err != nil {
+			return "", false, err
+		}
+
+		k := kindOf(value)
+		if kinds[i] == kindNull {
+			kinds[i] = k
+		}
+
+		key, ok := canonical(value)
+		if !ok || k != kinds[i] {
+			return "", false, nil
+		}
+
+		values[i] = key
+	}
+
+	if null {
+		return "", true, nil
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", false, err
+	}
+
+	return string(b), true, nil
+}
+
+// equiColumns resolves both sides of an Equals to one column of the left and one column of the right table.
+func equiColumns(e Equals, left, right []string) (int, int, bool) {
+	i0, ok := e[0].(Ident)
+	if !ok {
+		return 0, 0, false
+	}
+
+	i1, ok := e[1].(Ident)
+	if !ok {
+		return 0, 0, false
+	}
+
+	l0, r0 := resolveColumn(i0, left), resolveColumn(i0, right)
+	l1, r1 := resolveColumn(i1, left), resolveColumn(i1, right)
+
+	switch {
+	case l0 >= 0 && r0 == -1 && r1 >= 0 && l1 == -1:
+		return l0, r1, true
+	case r0 >= 0 && l0 == -1 && l1 >= 0 && r1 == -1:
+		return l1, r0, true
+	}
+
+	return 0, 0, false
+}
+
+// resolveColumn returns the index of the column, -1 if it is missing and -2 if it is ambiguous.
+func resolveColumn(i Ident, columns []string) int {
+	index, err := i.index(columns)
+	if err != nil {
+		return -2
+	}
+
+	return index
+}
+
+func renderJoin(kind string, right Query, on Condition) (string, error) {
+	r, err := renderFrom(right)
+	if err != nil {
+		return "", err
+	}
+
+	if on == nil {
+		return kind + " " + r, nil
+	}
+
+	c, err := render(on)
+	if err != nil {
+		return "", err
+	}
+
+	return kind + " " + r + " ON " + c, nil
+}
+
+type LeftJoin struct {
+	Right Query
+	On    Condition
+}
+
+func (lj LeftJoin) Join(left Query) Table {
+	return join(left, lj.Right, lj.On, true, false)
+}
+
+func (lj LeftJoin) SQL() (string, error) {
+	return renderJoin("LEFT JOIN", lj.Right, lj.On)
+}
+
+type InnerJoin struct {
+	Right Query
+	On    Condition
+}
+
+func (ij InnerJoin) Join(left Query) Table {
+	return join(left, ij.Right, ij.On, false, false)
+}
+
+func (ij InnerJoin) SQL() (string, error) {
+	return renderJoin("JOIN", ij.Right, ij.On)
+}
+
+type RightJoin struct {
+	Right Query
+	On    Condition
+}
+
+func (rj RightJoin) Join(left Query) Table {
+	return join(left, rj.Right, rj.On, false, true)
+}
+
+func (rj RightJoin) SQL() (string, error) {
+	return renderJoin("RIGHT JOIN", rj.Right, rj.On)
+}
+
+type FullJoin struct {
+	Right Query
+	On    Condition
+}
+
+func (fj FullJoin) Join(left Query) Table {
+	return join(left, fj.Right, fj.On, true, true)
+}
+
+func (fj FullJoin) SQL() (string, error) {
+	return renderJoin("FULL JOIN", fj.Right, fj.On)
+}
+
+type CrossJoin struct {
+	Right Query
+}
+
+func (cj CrossJoin) Join(left Query) Table {
+	return join(left, cj.Right, nil, false, false)
+}
+
+func (cj CrossJoin) SQL() (string, error) {
+	return renderJoin("CROSS JOIN", cj.Right, nil)
+}
@@ -0,0 +1,164 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wroge/querify"
+)
+
+func TestJoin(t *testing.T) {
+	left := querify.From([]map[string]interface{}{
+		{"id": 1, "name": "Max"},
+		{"id": 2, "name": "Tom"},
+	}).As("l")
+
+	right := querify.From([]map[string]interface{}{
+		{"id": 2, "name": "Basketball"},
+		{"id": 3, "name": "Hockey"},
+	}).As("r")
+
+	on := querify.Equals{querify.Ident("l.id"), querify.Ident("r.id")}
+
+	tests := []struct {
+		join querify.Join
+		want [][]interface{}
+	}{
+		{querify.InnerJoin{Right: right, On: on}, [][]interface{}{{"Tom", "Basketball"}}},
+		{querify.LeftJoin{Right: right, On: on}, [][]interface{}{{"Max", nil}, {"Tom", "Basketball"}}},
+		{querify.RightJoin{Right: right, On: on}, [][]interface{}{{"Tom", "Basketball"}, {nil, "Hockey"}}},
+		{querify.FullJoin{Right: right, On: on}, [][]interface{}{{"Max", nil}, {"Tom", "Basketball"}, {nil, "Hockey"}}},
+		{querify.CrossJoin{Right: right}, [][]interface{}{
+			{"Max", "Basketball"}, {"Max", "Hockey"}, {"Tom", "Basketball"}, {"Tom", "Hockey"},
+		}},
+	}
+
+	for _, test := range tests {
+		var got [][]interface{}
+
+		selected := left.Join(test.join).Select(querify.Ident("l.name"), querify.Ident("r.name"))
+		if selected.Err != nil {
+			t.Fatal(selected.Err)
+		}
+
+		for _, d := range selected.Selected.Data {
+			got = append(got, []interface{}{d[0], d[1]})
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatal(test.join, got)
+		}
+	}
+}
+
+func TestParseJoin(t *testing.T) {
+	stmt, err := querify.Parse(`
+		SELECT u.name, h.name AS hobby
+		FROM users u
+		JOIN user_hobbies uh ON u.id = uh.user_id
+		RIGHT OUTER JOIN hobbies h ON h.id = uh.hobby_id`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []struct {
+		Name  *string
+		Hobby string
+	}
+
+	if err = stmt.Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 4 {
+		t.Fatal(rows)
+	}
+
+	roundTrip(t, stmt, tables())
+}
+
+func TestHashJoin(t *testing.T) {
+	var orders, items []map[string]interface{}
+
+	for i := 0; i < 300; i++ {
+		orders = append(orders, map[string]interface{}{"id": i, "customer": i % 7})
+		items = append(items, map[string]interface{}{"order_id": i % 120, "customer": i % 3, "qty": i})
+	}
+
+	o := querify.From(orders).As("o")
+	i := querify.From(items).As("i")
+
+	equi := querify.And{
+		querify.Equals{querify.Ident("i.order_id"), querify.Ident("o.id")},
+		querify.Equals{querify.Ident("o.customer"), querify.Ident("i.customer")},
+		querify.Greater{querify.Ident("qty"), querify.Literal{Value: 50}},
+	}
+
+	// Or prevents the hash join, so the result is computed by the nested loop.
+	nested := querify.Or{equi, equi}
+
+	for _, j := range []func(on querify.Condition) querify.Join{
+		func(on querify.Condition) querify.Join { return querify.LeftJoin{Right: i, On: on} },
+		func(on querify.Condition) querify.Join { return querify.FullJoin{Right: i, On: on} },
+	} {
+		hashed := o.Join(j(equi))
+		if hashed.Err != nil {
+			t.Fatal(hashed.Err)
+		}
+
+		looped := o.Join(j(nested))
+		if looped.Err != nil {
+			t.Fatal(looped.Err)
+		}
+
+		if !reflect.DeepEqual(hashed.Data, looped.Data) {
+			t.Fatal(len(hashed.Data), len(looped.Data))
+		}
+	}
+}
+
+func TestHashJoinKeys(t *testing.T) {
+	table := func(name string, values ...querify.Value) querify.Table {
+		data := make([][]querify.Value, len(values))
+
+		for i, v := range values {
+			data[i] = []querify.Value{v}
+		}
+
+		return querify.Table{Columns: []string{"k"}, Data: data}.As(name)
+	}
+
+	at := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	on := querify.Equals{querify.Ident("l.k"), querify.Ident("r.k")}
+
+	tests := []struct {
+		left, right querify.Table
+		want        int
+	}{
+		{table("l", 1, int64(2), nil), table("r", 1.0, 2, nil), 2},
+		{table("l", at), table("r", "2021-01-02 03:04:05", "2021-01-02"), 1},
+		{table("l", at.In(time.FixedZone("", 3600))), table("r", at), 1},
+		{table("l", querify.Interval{Days: 1}), table("r", querify.Interval{Duration: 24 * time.Hour}), 1},
+	}
+
+	for _, test := range tests {
+		for _, condition := range []querify.Condition{on, querify.Or{on, on}} {
+			joined := test.left.Join(querify.InnerJoin{Right: test.right, On: condition})
+			if joined.Err != nil {
+				t.Fatal(joined.Err)
+			}
+
+			if len(joined.Data) != test.want {
+				t.Fatal(condition, joined.Data)
+			}
+		}
+	}
+
+	for _, condition := range []querify.Condition{on, querify.Or{on, on}} {
+		joined := table("l", 1).Join(querify.InnerJoin{Right: table("r", "1"), On: condition})
+		if joined.Err == nil {
+			t.Fatal(joined.Data)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package querify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// JsonGet returns the field of an object or the element of an array like '->'. Negative indexes count from the end.
+type JsonGet [2]Variable
+
+func (j JsonGet) Variable(record SelectedRecord) (Value, error) {
+	return jsonAccess(record, j[0], j[1], func(value, key Value) (Value, error) {
+		return jsonGet(value, key)
+	})
+}
+
+func (j JsonGet) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", j, table)
+}
+
+func (j JsonGet) SQL() (string, error) {
+	return renderJSON(j[0], "->", j[1])
+}
+
+// JsonGetText is like JsonGet, but returns text like '->>'.
+type JsonGetText [2]Variable
+
+func (j JsonGetText) Variable(record SelectedRecord) (Value, error) {
+	return jsonAccess(record, j[0], j[1], func(value, key Value) (Value, error) {
+		out, err := jsonGet(value, key)
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonText(out)
+	})
+}
+
+func (j JsonGetText) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", j, table)
+}
+
+func (j JsonGetText) SQL() (string, error) {
+	return renderJSON(j[0], "->>", j[1])
+}
+
+// JsonPath follows a path of keys like '#>'. The path is an array or an array literal like '{address,city}'.
+type JsonPath [2]Variable
+
+func (j JsonPath) Variable(record SelectedRecord) (Value, error) {
+	return jsonAccess(record, j[0], j[1], jsonPath)
+}
+
+func (j JsonPath) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", j, table)
+}
+
+func (j JsonPath) SQL() (string, error) {
+	return renderJSON(j[0], "#>", j[1])
+}
+
+// JsonPathText is like JsonPath, but returns text like '#>>'.
+type JsonPathText [2]Variable
+
+func (j JsonPathText) Variable(record SelectedRecord) (Value, error) {
+	return jsonAccess(record, j[0], j[1], func(value, path Value) (Value, error) {
+		out, err := jsonPath(value, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonText(out)
+	})
+}
+
+func (j JsonPathText) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", j, table)
+}
+
+func (j JsonPathText) SQL() (string, error) {
+	return renderJSON(j[0], "#>>", j[1])
+}
+
+// JsonQuery evaluates a gjson path like 'address.city'. Missing values are NULL.
+type JsonQuery struct {
+	Expression Variable
+	Path       string
+}
+
+func (j JsonQuery) Variable(record SelectedRecord) (Value, error) {
+	value, err := j.Expression.Variable(record)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(jsonDocument(j.Expression, value))
+	if err != nil {
+		return nil, err
+	}
+
+	result := gjson.GetBytes(b, j.Path)
+	if !result.Exists() {
+		return nil, nil
+	}
+
+	return result.Value(), nil
+}
+
+func (j JsonQuery) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("?column?", j, table)
+}
+
+func (j JsonQuery) SQL() (string, error) {
+	var (
+		keys []string
+		key  strings.Builder
+	)
+
+	for i := 0; i < len(j.Path); i++ {
+		switch c := j.Path[i]; c {
+		case '\\':
+			if i+1 < len(j.Path) {
+				i++
+				key.WriteByte(j.Path[i])
+			}
+		case '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		case '*', '?', '#', '|', '@', '!', '=', '<', '>', '%', '[', ']', '{', '}', '(', ')':
+			return "", fmt.Errorf("querify: cannot render gjson path '%s' as sql", j.Path)
+		default:
+			key.WriteByte(c)
+		}
+	}
+
+	keys = append(keys, key.String())
+
+	return renderJSON(j.Expression, "#>", Literal{Value: arrayLiteral(keys)})
+}
+
+// JsonContains is true if the left document contains the right one like '@>'.
+type JsonContains [2]Variable
+
+func (j JsonContains) Condition(record GroupedRecord) (bool, error) {
+	return holds(j, record)
+}
+
+func (j JsonContains) Predicate(record GroupedRecord) (Truth, error) {
+	return jsonCondition(j[0], j[1], record, func(left, right Value) (bool, error) {
+		return jsonContains(jsonDocument(j[0], left), jsonDocument(j[1], right), true), nil
+	})
+}
+
+func (j JsonContains) SQL() (string, error) {
+	return renderBinary(j[0], "@>", j[1])
+}
+
+// JsonContainedBy is JsonContains with swapped operands like '<@'.
+type JsonContainedBy [2]Variable
+
+func (j JsonContainedBy) Condition(record GroupedRecord) (bool, error) {
+	return holds(j, record)
+}
+
+func (j JsonContainedBy) Predicate(record GroupedRecord) (Truth, error) {
+	return jsonCondition(j[0], j[1], record, func(left, right Value) (bool, error) {
+		return jsonContains(jsonDocument(j[1], right), jsonDocument(j[0], left), true), nil
+	})
+}
+
+func (j JsonContainedBy) SQL() (string, error) {
+	return renderBinary(j[0], "<@", j[1])
+}
+
+// JsonHasKey is true if a string is a key of an object or an element of an array like '?'.
+type JsonHasKey [2]Variable
+
+func (j JsonHasKey) Condition(record GroupedRecord) (bool, error) {
+	return holds(j, record)
+}
+
+func (j JsonHasKey) Predicate(record GroupedRecord) (Truth, error) {
+	return jsonCondition(j[0], j[1], record, func(left, right Value) (bool, error) {
+		key, ok := right.(string)
+		if !ok {
+			return false, fmt.Errorf("querify: json key must be a string, got type '%s'", kindOf(right))
+		}
+
+		return jsonHasKey(jsonDocument(j[0], left), key), nil
+	})
+}
+
+func (j JsonHasKey) SQL() (string, error) {
+	return renderBinary(j[0], "?", j[1])
+}
+
+// JsonHasAnyKey is true if any of the keys exists like '?|'. The keys are an array or an array literal.
+type JsonHasAnyKey [2]Variable
+
+func (j JsonHasAnyKey) Condition(record GroupedRecord) (bool, error) {
+	return holds(j, record)
+}
+
+func (j JsonHasAnyKey) Predicate(record GroupedRecord) (Truth, error) {
+	return jsonCondition(j[0], j[1], record, func(left, right Value) (bool, error) {
+		return jsonHasKeys(jsonDocument(j[0], left), right, true)
+	})
+}
+
+func (j JsonHasAnyKey) SQL() (string, error) {
+	return renderBinary(j[0], "?|", j[1])
+}
+
+// JsonHasAllKeys is true if all of the keys exist like '?&'. The keys are an array or an array literal.
+type JsonHasAllKeys [2]Variable
+
+func (j JsonHasAllKeys) Condition(record GroupedRecord) (bool, error) {
+	return holds(j, record)
+}
+
+func (j JsonHasAllKeys) Predicate(record GroupedRecord) (Truth, error) {
+	return jsonCondition(j[0], j[1], record, func(left, right Value) (bool, error) {
+		return jsonHasKeys(jsonDocument(j[0], left), right, false)
+	})
+}
+
+func (j JsonHasAllKeys) SQL() (string, error) {
+	return renderBinary(j[0], "?&", j[1])
+}
+
+// jsonCondition evaluates both operands. A NULL operand is unknown.
+func jsonCondition(left, right Variable, record GroupedRecord,
+	test func(left, right Value) (bool, error),
+) (Truth, error) {
+	l, err := left.Variable(record.selected())
+	if err != nil {
+		return False, err
+	}
+
+	r, err := right.Variable(record.selected())
+	if err != nil {
+		return False, err
+	}
+
+	if l == nil || r == nil {
+		return Unknown, nil
+	}
+
+	if l, err = normalize(l); err != nil {
+		return False, err
+	}
+
+	if r, err = normalize(r); err != nil {
+		return False, err
+	}
+
+	ok, err := test(l, r)
+
+	return truth(ok), err
+}
+
+// jsonDocument parses text like '{"tags":["a"]}' like a json cast. Strings of json values stay strings.
+func jsonDocument(operand Variable, value Value) Value {
+	switch operand.(type) {
+	case JsonGet, JsonPath, JsonQuery:
+		return value
+	}
+
+	if s, ok := value.(string); ok {
+		var out interface{}
+
+		if err := json.Unmarshal([]byte(s), &out); err == nil {
+			return out
+		}
+	}
+
+	return value
+}
+
+func jsonContains(value, contained Value, top bool) bool {
+	switch c := contained.(type) {
+	case map[string]interface{}:
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for key, element := range c {
+			e, ok := v[key]
+			if !ok || !jsonContains(e, element, false) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		v, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+
+		for _, element := range c {
+			if !jsonContainsElement(v, element) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if v, ok := value.([]interface{}); ok && top {
+		return jsonContainsElement(v, contained)
+	}
+
+	if kindOf(value) != kindOf(contained) {
+		return false
+	}
+
+	if value == nil {
+		return true
+	}
+
+	c, err := compare(value, contained)
+
+	return err == nil && c == 0
+}
+
+func jsonContainsElement(array []interface{}, element Value) bool {
+	for _, e := range array {
+		if jsonContains(e, element, false) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonHasKey checks the keys of objects, the string elements of arrays and strings.
+func jsonHasKey(value Value, key string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		_, ok := v[key]
+
+		return ok
+	case []interface{}:
+		for _, e := range v {
+			if e == key {
+				return true
+			}
+		}
+	case string:
+		return v == key
+	}
+
+	return false
+}
+
+// jsonHasKeys ignores NULL keys. Without keys, any is false and all is true.
+func jsonHasKeys(value, keys Value, some bool) (bool, error) {
+	if s, ok := keys.(string); ok {
+		parsed, err := parseArrayLiteral(strings.TrimSpace(s))
+		if err != nil {
+			return false, fmt.Errorf("querify: invalid array of keys '%s'", s)
+		}
+
+		keys = parsed
+	}
+
+	array, ok := keys.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("querify: json keys must be an array, got type '%s'", kindOf(keys))
+	}
+
+	for _, k := range array {
+		if k == nil {
+			continue
+		}
+
+		key, ok := k.(string)
+		if !ok {
+			return false, fmt.Errorf("querify: json key must be a string, got type '%s'", kindOf(k))
+		}
+
+		if jsonHasKey(value, key) == some {
+			return some, nil
+		}
+	}
+
+	return !some, nil
+}
+
+// jsonAccess evaluates a document and a key. A NULL document or key results in NULL.
+func jsonAccess(record SelectedRecord, document, key Variable,
+	fn func(value, key Value) (Value, error),
+) (Value, error) {
+	value, err := document.Variable(record)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	k, err := key.Variable(record)
+	if err != nil || k == nil {
+		return nil, err
+	}
+
+	value, err = normalize(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return fn(jsonDocument(document, value), k)
+}
+
+// jsonGet returns NULL for missing keys, out of range indexes and scalar values.
+func jsonGet(value, key Value) (Value, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if k, ok := key.(string); ok {
+			return v[k], nil
+		}
+	case []interface{}:
+		if kindOf(key) == kindString {
+			return nil, nil
+		}
+
+		f, ok := toFloat(key)
+		if !ok {
+			return nil, fmt.Errorf("querify: cannot use type '%s' as json key", kindOf(key))
+		}
+
+		i := int(f)
+		if float64(i) != f {
+			return nil, nil
+		}
+
+		if i < 0 {
+			i += len(v)
+		}
+
+		if i >= 0 && i < len(v) {
+			return v[i], nil
+		}
+	}
+
+	if kind := kindOf(key); kind != kindString && kind != kindNumber {
+		return nil, fmt.Errorf("querify: cannot use type '%s' as json key", kind)
+	}
+
+	return nil, nil
+}
+
+func jsonPath(value, path Value) (Value, error) {
+	if s, ok := path.(string); ok {
+		keys, err := parseArrayLiteral(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("querify: invalid json path '%s'", s)
+		}
+
+		path = keys
+	}
+
+	keys, ok := path.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("querify: json path must be an array, got type '%s'", kindOf(path))
+	}
+
+	for _, k := range keys {
+		if value == nil || k == nil {
+			return nil, nil
+		}
+
+		if s, ok := k.(string); ok {
+			if _, isArray := value.([]interface{}); isArray {
+				i, err := integer(s)
+				if err != nil {
+					return nil, nil
+				}
+
+				k = int64(i)
+			}
+		}
+
+		var err error
+
+		value, err = jsonGet(value, k)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return value, nil
+}
+
+// jsonText returns strings unquoted and all other values as json.
+func jsonText(value Value) (Value, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	return castText(value)
+}
+
+// renderJSON encloses nested json operators on the right side in parentheses, since they are left-associative.
+func renderJSON(left Variable, op string, right Variable) (string, error) {
+	l, err := render(left)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := render(right)
+	if err != nil {
+		return "", err
+	}
+
+	switch right.(type) {
+	case JsonGet, JsonGetText, JsonPath, JsonPathText, JsonQuery:
+		r = "(" + r + ")"
+	}
+
+	return l + " " + op + " " + r, nil
+}
+
+// arrayLiteral formats strings as a Postgres array literal like '{address,city}'.
+func arrayLiteral(elements []string) string {
+	out := make([]string, len(elements))
+
+	for i, e := range elements {
+		if e == "" || strings.ContainsAny(e, ",{}\"\\ ") || strings.EqualFold(e, "null") {
+			e = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(e) + `"`
+		}
+
+		out[i] = e
+	}
+
+	return "{" + strings.Join(out, ",") + "}"
+}
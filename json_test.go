@@ -0,0 +1,239 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func people() map[string]querify.Query {
+	return map[string]querify.Query{
+		"people": querify.From([]map[string]interface{}{
+			{"id": 1, "doc": map[string]interface{}{"name": "Max", "address": map[string]interface{}{"city": "Berlin"},
+				"tags": []string{"a", "b"}}},
+			{"id": 2, "doc": map[string]interface{}{"name": "Tom", "address": map[string]interface{}{"city": "Hamburg"},
+				"tags": []string{}}},
+			{"id": 3, "doc": map[string]interface{}{"name": "Alex", "address": map[string]interface{}{"city": "Berlin"}}},
+		}),
+	}
+}
+
+func TestJSON(t *testing.T) {
+	record := querify.SelectedRecord{Source: querify.Record{
+		Columns: []string{"doc", "null"},
+		Values: []querify.Value{map[string]interface{}{
+			"name":    "Max",
+			"age":     30.0,
+			"address": map[string]interface{}{"city": "Berlin", "zip": nil},
+			"tags":    []interface{}{"a", "b", "c"},
+		}, nil},
+	}}
+
+	doc, null := querify.Ident("doc"), querify.Ident("null")
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+	encoded := lit(`{"a":"{\"b\":1}"}`)
+
+	tests := []struct {
+		variable querify.Variable
+		want     querify.Value
+	}{
+		{querify.JsonGet{doc, lit("name")}, "Max"},
+		{querify.JsonGet{doc, lit("missing")}, nil},
+		{querify.JsonGet{doc, null}, nil},
+		{querify.JsonGet{null, lit("name")}, nil},
+		{querify.JsonGet{querify.JsonGet{doc, lit("tags")}, lit(1)}, "b"},
+		{querify.JsonGet{querify.JsonGet{doc, lit("tags")}, lit(-1)}, "c"},
+		{querify.JsonGet{querify.JsonGet{doc, lit("tags")}, lit(3)}, nil},
+		{querify.JsonGet{querify.JsonGet{doc, lit("tags")}, lit("0")}, nil},
+		{querify.JsonGet{querify.JsonGet{doc, lit("name")}, lit("x")}, nil},
+		{querify.JsonGetText{doc, lit("age")}, "30"},
+		{querify.JsonGetText{doc, lit("tags")}, `["a","b","c"]`},
+		{querify.JsonGetText{querify.JsonGet{doc, lit("address")}, lit("zip")}, nil},
+		{querify.JsonPath{doc, lit("{address,city}")}, "Berlin"},
+		{querify.JsonPath{doc, lit([]interface{}{"tags", "0"})}, "a"},
+		{querify.JsonPath{doc, lit("{address,city,x}")}, nil},
+		{querify.JsonPath{doc, lit("{tags,x}")}, nil},
+		{querify.JsonPathText{doc, lit("{address}")}, `{"city":"Berlin","zip":null}`},
+		{querify.JsonQuery{Expression: doc, Path: "address.city"}, "Berlin"},
+		{querify.JsonQuery{Expression: doc, Path: "tags.#"}, 3.0},
+		{querify.JsonQuery{Expression: doc, Path: "address.missing"}, nil},
+		{querify.JsonQuery{Expression: null, Path: "address"}, nil},
+		{querify.JsonGet{lit(`{"name":"Tom"}`), lit("name")}, "Tom"},
+		{querify.JsonGetText{lit(`[1,2]`), lit(-1)}, "2"},
+		{querify.JsonPath{lit(`{"a":{"b":true}}`), lit("{a,b}")}, true},
+		{querify.JsonGet{lit("Tom"), lit("name")}, nil},
+		{querify.JsonQuery{Expression: lit(`{"a":[1,2]}`), Path: "a.1"}, 2.0},
+		{querify.JsonGet{encoded, lit("a")}, `{"b":1}`},
+		{querify.JsonGet{querify.JsonGet{encoded, lit("a")}, lit("b")}, nil},
+		{querify.JsonPath{encoded, lit("{a,b}")}, nil},
+		{querify.JsonQuery{Expression: querify.JsonGet{encoded, lit("a")}, Path: "b"}, nil},
+		{querify.JsonGet{lit(`{"a":"123"}`), lit("a")}, "123"},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Variable(record)
+		if err != nil {
+			t.Fatal(test.variable, err)
+		}
+
+		if got != test.want {
+			t.Fatalf("%v: %#v", test.variable, got)
+		}
+	}
+
+	got, err := querify.JsonGet{doc, lit("address")}.Variable(record)
+	if err != nil || !reflect.DeepEqual(got, map[string]interface{}{"city": "Berlin", "zip": nil}) {
+		t.Fatal(got, err)
+	}
+
+	for _, v := range []querify.Variable{
+		querify.JsonGet{doc, lit(true)},
+		querify.JsonPath{doc, lit("address")},
+		querify.JsonPath{doc, lit(1)},
+	} {
+		if _, err := v.Variable(record); err == nil {
+			t.Fatal(v)
+		}
+	}
+
+	sql, err := querify.JsonQuery{Expression: doc, Path: `address.zip\.code`}.SQL()
+	if err != nil || sql != `doc #> '{address,zip.code}'` {
+		t.Fatal(sql, err)
+	}
+
+	if _, err = (querify.JsonQuery{Expression: doc, Path: "tags.#"}).SQL(); err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	stmt, err := querify.Parse(`SELECT p.doc->>'name' AS name, doc #>> '{address,city}' AS city, doc->'tags'->0 AS tag, `+
+		`gjson(doc, 'tags.1') AS tags `+
+		`FROM people p WHERE doc->'address'->>'city' = 'Berlin' ORDER BY id`, people())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"name": "Max", "city": "Berlin", "tag": "a", "tags": "b"},
+		{"name": "Alex", "city": "Berlin", "tag": nil, "tags": nil},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, people())
+
+	stmt, err = querify.Parse(`SELECT city, count(*) AS count `+
+		`FROM (SELECT gjson(doc, 'address.city') AS city FROM people) AS p GROUP BY city ORDER BY city`, people())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out = nil
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []map[string]interface{}{
+		{"city": "Berlin", "count": 2.0},
+		{"city": "Hamburg", "count": 1.0},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+}
+
+func TestJSONCondition(t *testing.T) {
+	record := querify.GroupedRecord{Source: querify.Record{
+		Columns: []string{"doc", "null"},
+		Values: []querify.Value{map[string]interface{}{
+			"name": "Max",
+			"age":  30.0,
+			"tags": []interface{}{"a", "b", []interface{}{"c"}},
+		}, nil},
+	}}
+
+	doc, null := querify.Ident("doc"), querify.Ident("null")
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+	encoded := lit(`{"a":"{\"b\":1}"}`)
+
+	tests := []struct {
+		condition querify.Condition
+		want      querify.Truth
+	}{
+		{querify.JsonContains{doc, lit(`{"name":"Max","tags":["b"]}`)}, querify.True},
+		{querify.JsonContains{doc, lit(map[string]interface{}{"age": 30})}, querify.True},
+		{querify.JsonContains{doc, lit(`{"tags":[["c"]]}`)}, querify.True},
+		{querify.JsonContains{doc, lit(`{"tags":"a"}`)}, querify.False},
+		{querify.JsonContains{doc, lit(`{"name":"Tom"}`)}, querify.False},
+		{querify.JsonContains{doc, lit(`{}`)}, querify.True},
+		{querify.JsonContains{lit(`["a","b"]`), lit("a")}, querify.True},
+		{querify.JsonContains{lit(`[1,2]`), lit(`[2,1,1]`)}, querify.True},
+		{querify.JsonContains{doc, null}, querify.Unknown},
+		{querify.JsonContainedBy{lit(`{"name":"Max"}`), doc}, querify.True},
+		{querify.JsonContainedBy{doc, lit(`{"name":"Max"}`)}, querify.False},
+		{querify.JsonHasKey{doc, lit("tags")}, querify.True},
+		{querify.JsonHasKey{doc, lit("x")}, querify.False},
+		{querify.JsonHasKey{querify.JsonGet{doc, lit("tags")}, lit("b")}, querify.True},
+		{querify.JsonHasKey{null, lit("x")}, querify.Unknown},
+		{querify.JsonHasAnyKey{doc, lit("{x,age}")}, querify.True},
+		{querify.JsonHasAnyKey{doc, lit([]interface{}{})}, querify.False},
+		{querify.JsonHasAllKeys{doc, lit("{name,age}")}, querify.True},
+		{querify.JsonHasAllKeys{doc, lit([]interface{}{"name", "x"})}, querify.False},
+		{querify.JsonHasAllKeys{doc, lit("{}")}, querify.True},
+		{querify.JsonHasKey{querify.JsonGet{encoded, lit("a")}, lit("b")}, querify.False},
+		{querify.JsonContains{querify.JsonGet{lit(`{"a":"true"}`), lit("a")}, lit(true)}, querify.False},
+	}
+
+	for _, test := range tests {
+		got, err := test.condition.(querify.Predicate).Predicate(record)
+		if err != nil {
+			t.Fatal(test.condition, err)
+		}
+
+		if got != test.want {
+			t.Fatal(test.condition, got)
+		}
+	}
+
+	for _, c := range []querify.Condition{
+		querify.JsonHasKey{doc, lit(1)},
+		querify.JsonHasAnyKey{doc, lit("x")},
+		querify.JsonHasAllKeys{doc, lit([]interface{}{1})},
+	} {
+		if _, err := c.Condition(record); err == nil {
+			t.Fatal(c)
+		}
+	}
+}
+
+func TestParseJSONCondition(t *testing.T) {
+	stmt, err := querify.Parse(`SELECT id FROM people WHERE doc @> '{"address":{"city":"Berlin"}}' AND doc ? 'tags' `+
+		`OR doc->'tags' ?| '{b,c}' AND NOT doc ?& '{name,tags}' OR '{"name":"Tom"}' <@ doc ORDER BY id`, people())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, []map[string]interface{}{{"id": 1.0}, {"id": 2.0}}) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, people())
+}
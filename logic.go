@@ -0,0 +1,191 @@
+package querify
+
+import (
+	"strings"
+)
+
+// Truth is the result of a condition in three-valued logic.
+type Truth uint8
+
+const (
+	False Truth = iota
+	True
+	Unknown
+)
+
+func truth(b bool) Truth {
+	if b {
+		return True
+	}
+
+	return False
+}
+
+func (t Truth) Not() Truth {
+	switch t {
+	case True:
+		return False
+	case False:
+		return True
+	}
+
+	return Unknown
+}
+
+func (t Truth) And(u Truth) Truth {
+	switch {
+	case t == False || u == False:
+		return False
+	case t == Unknown || u == Unknown:
+		return Unknown
+	}
+
+	return True
+}
+
+func (t Truth) Or(u Truth) Truth {
+	switch {
+	case t == True || u == True:
+		return True
+	case t == Unknown || u == Unknown:
+		return Unknown
+	}
+
+	return False
+}
+
+func (t Truth) String() string {
+	switch t {
+	case True:
+		return "true"
+	case False:
+		return "false"
+	}
+
+	return "unknown"
+}
+
+// evaluate evaluates conditions, that do not implement Predicate, as two-valued conditions.
+func evaluate(condition Condition, record GroupedRecord) (Truth, error) {
+	if p, ok := condition.(Predicate); ok {
+		return p.Predicate(record)
+	}
+
+	ok, err := condition.Condition(record)
+
+	return truth(ok), err
+}
+
+func holds(predicate Predicate, record GroupedRecord) (bool, error) {
+	t, err := predicate.Predicate(record)
+
+	return t == True, err
+}
+
+type And []Condition
+
+func (a And) Condition(record GroupedRecord) (bool, error) {
+	return holds(a, record)
+}
+
+func (a And) Predicate(record GroupedRecord) (Truth, error) {
+	result := True
+
+	for _, c := range a {
+		t, err := evaluate(c, record)
+		if err != nil {
+			return False, err
+		}
+
+		switch t {
+		case False:
+			return False, nil
+		case Unknown:
+			result = Unknown
+		}
+	}
+
+	return result, nil
+}
+
+func (a And) SQL() (string, error) {
+	if len(a) == 0 {
+		return "TRUE", nil
+	}
+
+	out := make([]string, len(a))
+
+	for i, c := range a {
+		s, err := render(c)
+		if err != nil {
+			return "", err
+		}
+
+		if _, ok := c.(Or); ok {
+			s = "(" + s + ")"
+		}
+
+		out[i] = s
+	}
+
+	return strings.Join(out, " AND "), nil
+}
+
+type Or [2]Condition
+
+func (o Or) Condition(record GroupedRecord) (bool, error) {
+	return holds(o, record)
+}
+
+func (o Or) Predicate(record GroupedRecord) (Truth, error) {
+	result := False
+
+	for _, c := range o {
+		t, err := evaluate(c, record)
+		if err != nil {
+			return False, err
+		}
+
+		switch t {
+		case True:
+			return True, nil
+		case Unknown:
+			result = Unknown
+		}
+	}
+
+	return result, nil
+}
+
+func (o Or) SQL() (string, error) {
+	return renderBinary(o[0], "OR", o[1])
+}
+
+type Not [1]Condition
+
+func (n Not) Condition(record GroupedRecord) (bool, error) {
+	return holds(n, record)
+}
+
+func (n Not) Predicate(record GroupedRecord) (Truth, error) {
+	t, err := evaluate(n[0], record)
+	if err != nil {
+		return False, err
+	}
+
+	return t.Not(), nil
+}
+
+func (n Not) SQL() (string, error) {
+	s, err := render(n[0])
+	if err != nil {
+		return "", err
+	}
+
+	switch n[0].(type) {
+	case And, Or:
+		return "NOT (" + s + ")", nil
+	}
+
+	return "NOT " + s, nil
+}
@@ -0,0 +1,75 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestLogic(t *testing.T) {
+	record := querify.GroupedRecord{Source: querify.Record{
+		Columns: []string{"a", "null"},
+		Values:  []querify.Value{1, nil},
+	}}
+
+	yes := querify.Equals{querify.Ident("a"), querify.Literal{Value: 1}}
+	no := querify.Equals{querify.Ident("a"), querify.Literal{Value: 2}}
+	unknown := querify.Equals{querify.Ident("null"), querify.Literal{Value: 1}}
+
+	tests := []struct {
+		condition querify.Condition
+		want      querify.Truth
+	}{
+		{querify.Not{unknown}, querify.Unknown},
+		{querify.Not{no}, querify.True},
+		{querify.And{yes, unknown}, querify.Unknown},
+		{querify.And{no, unknown}, querify.False},
+		{querify.Or{yes, unknown}, querify.True},
+		{querify.Or{no, unknown}, querify.Unknown},
+		{querify.Not{querify.Or{no, unknown}}, querify.Unknown},
+	}
+
+	for _, test := range tests {
+		got, err := test.condition.(querify.Predicate).Predicate(record)
+		if err != nil {
+			t.Fatal(test.condition, err)
+		}
+
+		if got != test.want {
+			t.Fatal(test.condition, got)
+		}
+
+		ok, err := test.condition.Condition(record)
+		if err != nil || ok != (test.want == querify.True) {
+			t.Fatal(test.condition, ok, err)
+		}
+	}
+}
+
+func TestParseNot(t *testing.T) {
+	data := tables()
+	data["scores"] = querify.From([]map[string]interface{}{
+		{"id": 1, "score": 10},
+		{"id": 2, "score": nil},
+		{"id": 3, "score": 30},
+	})
+
+	stmt, err := querify.Parse(`SELECT id FROM scores WHERE NOT score = 10 AND id NOT BETWEEN 4 AND 5 `+
+		`OR NOT (id < 3 OR score > 20)`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+
+	if err = stmt.Execute().ScanColumn("id", &ids); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ids, []int{3}) {
+		t.Fatal(ids)
+	}
+
+	roundTrip(t, stmt, data)
+}
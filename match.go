@@ -0,0 +1,309 @@
+package querify
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Like matches with the wildcards '%' and '_'. Escape defaults to a backslash, an empty escape disables escaping.
+type Like struct {
+	Expression Variable
+	Pattern    Variable
+	Escape     Variable
+}
+
+func (l Like) Condition(record GroupedRecord) (bool, error) {
+	return holds(l, record)
+}
+
+func (l Like) Predicate(record GroupedRecord) (Truth, error) {
+	return like(l.Expression, l.Pattern, l.Escape, record, false)
+}
+
+func (l Like) SQL() (string, error) {
+	return renderLike(l.Expression, "LIKE", l.Pattern, l.Escape)
+}
+
+type ILike struct {
+	Expression Variable
+	Pattern    Variable
+	Escape     Variable
+}
+
+func (il ILike) Condition(record GroupedRecord) (bool, error) {
+	return holds(il, record)
+}
+
+func (il ILike) Predicate(record GroupedRecord) (Truth, error) {
+	return like(il.Expression, il.Pattern, il.Escape, record, true)
+}
+
+func (il ILike) SQL() (string, error) {
+	return renderLike(il.Expression, "ILIKE", il.Pattern, il.Escape)
+}
+
+type Match [2]Variable
+
+func (m Match) Condition(record GroupedRecord) (bool, error) {
+	return holds(m, record)
+}
+
+func (m Match) Predicate(record GroupedRecord) (Truth, error) {
+	return matches(m[0], m[1], record, cacheable(m[1]), regex(""))
+}
+
+func (m Match) SQL() (string, error) {
+	return renderBinary(m[0], "~", m[1])
+}
+
+type IMatch [2]Variable
+
+func (im IMatch) Condition(record GroupedRecord) (bool, error) {
+	return holds(im, record)
+}
+
+func (im IMatch) Predicate(record GroupedRecord) (Truth, error) {
+	return matches(im[0], im[1], record, cacheable(im[1]), regex("(?i)"))
+}
+
+func (im IMatch) SQL() (string, error) {
+	return renderBinary(im[0], "~*", im[1])
+}
+
+type NotMatch [2]Variable
+
+func (nm NotMatch) Condition(record GroupedRecord) (bool, error) {
+	return holds(nm, record)
+}
+
+func (nm NotMatch) Predicate(record GroupedRecord) (Truth, error) {
+	t, err := matches(nm[0], nm[1], record, cacheable(nm[1]), regex(""))
+
+	return t.Not(), err
+}
+
+func (nm NotMatch) SQL() (string, error) {
+	return renderBinary(nm[0], "!~", nm[1])
+}
+
+type NotIMatch [2]Variable
+
+func (nim NotIMatch) Condition(record GroupedRecord) (bool, error) {
+	return holds(nim, record)
+}
+
+func (nim NotIMatch) Predicate(record GroupedRecord) (Truth, error) {
+	t, err := matches(nim[0], nim[1], record, cacheable(nim[1]), regex("(?i)"))
+
+	return t.Not(), err
+}
+
+func (nim NotIMatch) SQL() (string, error) {
+	return renderBinary(nim[0], "!~*", nim[1])
+}
+
+// regexps caches the compiled patterns of literals. The least recently used patterns are evicted.
+var regexps = &regexpCache{capacity: 256, entries: map[string]*list.Element{}, order: list.New()}
+
+type regexpCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type cachedRegexp struct {
+	source string
+	re     *regexp.Regexp
+}
+
+func (c *regexpCache) load(source string) (*regexp.Regexp, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[source]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+
+	return e.Value.(cachedRegexp).re, true
+}
+
+func (c *regexpCache) store(source string, re *regexp.Regexp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[source]; ok {
+		c.order.MoveToFront(e)
+
+		return
+	}
+
+	c.entries[source] = c.order.PushFront(cachedRegexp{source: source, re: re})
+
+	for c.order.Len() > c.capacity {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.entries, last.Value.(cachedRegexp).source)
+	}
+}
+
+func compile(source string, cache bool) (*regexp.Regexp, error) {
+	if re, ok := regexps.load(source); ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, fmt.Errorf("querify: invalid regular expression '%s'", source)
+	}
+
+	if cache {
+		regexps.store(source, re)
+	}
+
+	return re, nil
+}
+
+func cacheable(variables ...Variable) bool {
+	for _, v := range variables {
+		if _, ok := v.(Literal); v != nil && !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func regex(flags string) func(pattern string) (string, error) {
+	return func(pattern string) (string, error) {
+		return flags + pattern, nil
+	}
+}
+
+// matches matches the text of expression against the regular expression translated from pattern.
+func matches(expression, pattern Variable, record GroupedRecord, cache bool,
+	translate func(pattern string) (string, error)) (Truth, error) {
+	v, err := expression.Variable(record.selected())
+	if err != nil {
+		return False, err
+	}
+
+	p, err := pattern.Variable(record.selected())
+	if err != nil {
+		return False, err
+	}
+
+	if v == nil || p == nil {
+		return Unknown, nil
+	}
+
+	text, ok := v.(string)
+	if !ok {
+		return False, fmt.Errorf("querify: cannot match type '%s'", kindOf(v))
+	}
+
+	pat, ok := p.(string)
+	if !ok {
+		return False, fmt.Errorf("querify: cannot match pattern of type '%s'", kindOf(p))
+	}
+
+	source, err := translate(pat)
+	if err != nil {
+		return False, err
+	}
+
+	re, err := compile(source, cache)
+	if err != nil {
+		return False, err
+	}
+
+	return truth(re.MatchString(text)), nil
+}
+
+func like(expression, pattern, escape Variable, record GroupedRecord, insensitive bool) (Truth, error) {
+	esc := Value(`\`)
+
+	if escape != nil {
+		var err error
+
+		esc, err = escape.Variable(record.selected())
+		if err != nil {
+			return False, err
+		}
+
+		if esc == nil {
+			return Unknown, nil
+		}
+	}
+
+	e, ok := esc.(string)
+	if !ok || utf8.RuneCountInString(e) > 1 {
+		return False, fmt.Errorf("querify: invalid escape '%v'", esc)
+	}
+
+	return matches(expression, pattern, record, cacheable(pattern, escape), func(pattern string) (string, error) {
+		return likeRegex(pattern, e, insensitive)
+	})
+}
+
+func likeRegex(pattern, escape string, insensitive bool) (string, error) {
+	b := strings.Builder{}
+
+	if insensitive {
+		b.WriteString("(?i)")
+	}
+
+	b.WriteString("(?s)^")
+
+	escaped := false
+
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+
+			escaped = false
+		case escape != "" && string(r) == escape:
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	if escaped {
+		return "", fmt.Errorf("querify: like pattern '%s' ends with escape", pattern)
+	}
+
+	b.WriteString("$")
+
+	return b.String(), nil
+}
+
+func renderLike(expression Variable, op string, pattern, escape Variable) (string, error) {
+	s, err := renderBinary(expression, op, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	if escape == nil {
+		return s, nil
+	}
+
+	e, err := render(escape)
+	if err != nil {
+		return "", err
+	}
+
+	return s + " ESCAPE " + e, nil
+}
@@ -0,0 +1,104 @@
+package querify_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestMatch(t *testing.T) {
+	record := querify.GroupedRecord{Source: querify.Record{
+		Columns: []string{"text", "null", "number"},
+		Values:  []querify.Value{"100% Max_1\nTom", nil, 1},
+	}}
+
+	text := querify.Ident("text")
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+
+	tests := []struct {
+		condition querify.Condition
+		want      querify.Truth
+	}{
+		{querify.Like{Expression: text, Pattern: lit("100%")}, querify.True},
+		{querify.Like{Expression: text, Pattern: lit("100\\%%")}, querify.True},
+		{querify.Like{Expression: text, Pattern: lit("1000%")}, querify.False},
+		{querify.Like{Expression: text, Pattern: lit("%max%")}, querify.False},
+		{querify.Like{Expression: text, Pattern: lit("%Max!__%"), Escape: lit("!")}, querify.True},
+		{querify.Like{Expression: text, Pattern: lit("%Max!_x%"), Escape: lit("!")}, querify.False},
+		{querify.Like{Expression: text, Pattern: lit("%\\%"), Escape: lit("")}, querify.False},
+		{querify.Like{Expression: text, Pattern: querify.Ident("null")}, querify.Unknown},
+		{querify.ILike{Expression: text, Pattern: lit("%max%tom")}, querify.True},
+		{querify.Match{text, lit("^100")}, querify.True},
+		{querify.Match{text, lit("tom$")}, querify.False},
+		{querify.IMatch{text, lit("tom$")}, querify.True},
+		{querify.NotMatch{text, lit("[0-9]+")}, querify.False},
+		{querify.NotIMatch{text, lit("z")}, querify.True},
+		{querify.NotMatch{querify.Ident("null"), lit("x")}, querify.Unknown},
+	}
+
+	for _, test := range tests {
+		got, err := test.condition.(querify.Predicate).Predicate(record)
+		if err != nil {
+			t.Fatal(test.condition, err)
+		}
+
+		if got != test.want {
+			t.Fatal(test.condition, got)
+		}
+	}
+
+	for _, c := range []querify.Condition{
+		querify.Like{Expression: querify.Ident("number"), Pattern: lit("1")},
+		querify.Like{Expression: text, Pattern: lit("100\\")},
+		querify.Like{Expression: text, Pattern: lit("100"), Escape: lit("ab")},
+		querify.Match{text, lit("(")},
+	} {
+		if _, err := c.Condition(record); err == nil {
+			t.Fatal(c)
+		}
+	}
+}
+
+func TestMatchCache(t *testing.T) {
+	record := querify.GroupedRecord{Source: querify.Record{Columns: []string{"text"}, Values: []querify.Value{"a1"}}}
+
+	for i := 0; i < 1000; i++ {
+		ok, err := querify.Match{querify.Ident("text"), querify.Literal{Value: "a" + strconv.Itoa(i)}}.Condition(record)
+		if err != nil || ok != (i == 1) {
+			t.Fatal(i, ok, err)
+		}
+	}
+
+	if n := querify.CachedRegexps(); n == 0 || n > 256 {
+		t.Fatal(n)
+	}
+}
+
+func TestParseMatch(t *testing.T) {
+	stmt, err := querify.Parse(`SELECT name FROM hobbies WHERE name LIKE '%ball' AND name NOT ILIKE 'foot%' `+
+		`OR name ~* '^h.*y$' AND name !~ 'x'`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"Basketball", "Hockey"}) {
+		t.Fatal(names)
+	}
+
+	roundTrip(t, stmt, tables())
+
+	stmt, err = querify.Parse(`SELECT name FROM hobbies WHERE name LIKE '%#_%' ESCAPE '#'`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roundTrip(t, stmt, tables())
+}
@@ -0,0 +1,399 @@
+package querify
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+)
+
+var random = rand.Float64
+
+// SetRandomSource replaces the source of Random and returns a function that restores it.
+func SetRandomSource(source func() float64) (restore func()) {
+	previous := random
+	random = source
+
+	return func() { random = previous }
+}
+
+type Abs [1]Variable
+
+func (a Abs) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "abs", func(args []Value) (Value, error) {
+		if i, ok := toInt(args[0]); ok {
+			if i == math.MinInt64 {
+				return nil, fmt.Errorf("querify: integer out of range")
+			}
+
+			if i < 0 {
+				return -i, nil
+			}
+
+			return i, nil
+		}
+
+		f, _ := toFloat(args[0])
+
+		return math.Abs(f), nil
+	}, a[0])
+}
+
+func (a Abs) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("abs", a, table)
+}
+
+func (a Abs) SQL() (string, error) {
+	return renderCall("abs", a[0])
+}
+
+// Round rounds half away from zero to Places decimal places, or to an integer without Places.
+type Round struct {
+	Expression Variable
+	Places     Variable
+}
+
+func (r Round) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "round", func(args []Value) (Value, error) {
+		return decimal(args, false)
+	}, optional(r.Expression, r.Places)...)
+}
+
+func (r Round) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("round", r, table)
+}
+
+func (r Round) SQL() (string, error) {
+	return renderCall("round", optional(r.Expression, r.Places)...)
+}
+
+// Trunc truncates towards zero to Places decimal places, or to an integer without Places.
+type Trunc struct {
+	Expression Variable
+	Places     Variable
+}
+
+func (t Trunc) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "trunc", func(args []Value) (Value, error) {
+		return decimal(args, true)
+	}, optional(t.Expression, t.Places)...)
+}
+
+func (t Trunc) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("trunc", t, table)
+}
+
+func (t Trunc) SQL() (string, error) {
+	return renderCall("trunc", optional(t.Expression, t.Places)...)
+}
+
+type Ceil [1]Variable
+
+func (c Ceil) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "ceil", integral(math.Ceil), c[0])
+}
+
+func (c Ceil) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("ceil", c, table)
+}
+
+func (c Ceil) SQL() (string, error) {
+	return renderCall("ceil", c[0])
+}
+
+type Floor [1]Variable
+
+func (f Floor) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "floor", integral(math.Floor), f[0])
+}
+
+func (f Floor) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("floor", f, table)
+}
+
+func (f Floor) SQL() (string, error) {
+	return renderCall("floor", f[0])
+}
+
+// Sign returns -1, 0 or 1.
+type Sign [1]Variable
+
+func (s Sign) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "sign", func(args []Value) (Value, error) {
+		f, _ := toFloat(args[0])
+
+		var sign float64
+
+		switch {
+		case f > 0:
+			sign = 1
+		case f < 0:
+			sign = -1
+		}
+
+		if _, ok := toInt(args[0]); ok {
+			return int64(sign), nil
+		}
+
+		return sign, nil
+	}, s[0])
+}
+
+func (s Sign) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("sign", s, table)
+}
+
+func (s Sign) SQL() (string, error) {
+	return renderCall("sign", s[0])
+}
+
+type Power [2]Variable
+
+func (p Power) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "power", func(args []Value) (Value, error) {
+		x, _ := toFloat(args[0])
+		y, _ := toFloat(args[1])
+
+		switch {
+		case x == 0 && y < 0:
+			return nil, fmt.Errorf("querify: zero raised to a negative power is undefined")
+		case x < 0 && y != math.Trunc(y):
+			return nil, fmt.Errorf("querify: a negative number raised to a non-integer power yields a complex result")
+		}
+
+		return math.Pow(x, y), nil
+	}, p[:]...)
+}
+
+func (p Power) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("power", p, table)
+}
+
+func (p Power) SQL() (string, error) {
+	return renderCall("power", p[:]...)
+}
+
+type Sqrt [1]Variable
+
+func (s Sqrt) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "sqrt", func(args []Value) (Value, error) {
+		f, _ := toFloat(args[0])
+		if f < 0 {
+			return nil, fmt.Errorf("querify: cannot take square root of a negative number")
+		}
+
+		return math.Sqrt(f), nil
+	}, s[0])
+}
+
+func (s Sqrt) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("sqrt", s, table)
+}
+
+func (s Sqrt) SQL() (string, error) {
+	return renderCall("sqrt", s[0])
+}
+
+// Ln returns the natural logarithm.
+type Ln [1]Variable
+
+func (l Ln) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "ln", func(args []Value) (Value, error) {
+		f, _ := toFloat(args[0])
+
+		return logarithm(f, math.E)
+	}, l[0])
+}
+
+func (l Ln) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("ln", l, table)
+}
+
+func (l Ln) SQL() (string, error) {
+	return renderCall("ln", l[0])
+}
+
+// Log returns the logarithm to Base, or to base 10 without Base.
+type Log struct {
+	Expression Variable
+	Base       Variable
+}
+
+func (l Log) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "log", func(args []Value) (Value, error) {
+		f, _ := toFloat(args[0])
+		base := 10.0
+
+		if len(args) > 1 {
+			base, _ = toFloat(args[1])
+		}
+
+		return logarithm(f, base)
+	}, optional(l.Expression, l.Base)...)
+}
+
+func (l Log) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("log", l, table)
+}
+
+func (l Log) SQL() (string, error) {
+	if l.Base == nil {
+		return renderCall("log", l.Expression)
+	}
+
+	return renderCall("log", l.Base, l.Expression)
+}
+
+type Exp [1]Variable
+
+func (e Exp) Variable(record SelectedRecord) (Value, error) {
+	return numeric(record, "exp", func(args []Value) (Value, error) {
+		f, _ := toFloat(args[0])
+
+		return math.Exp(f), nil
+	}, e[0])
+}
+
+func (e Exp) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("exp", e, table)
+}
+
+func (e Exp) SQL() (string, error) {
+	return renderCall("exp", e[0])
+}
+
+// Random returns a random value between 0 and 1.
+type Random struct{}
+
+func (Random) Variable(record SelectedRecord) (Value, error) {
+	return random(), nil
+}
+
+func (r Random) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("random", r, table)
+}
+
+func (Random) SQL() (string, error) {
+	return "random()", nil
+}
+
+// numeric evaluates the variables and returns NULL, if one of them is NULL. All values must be numbers.
+func numeric(record SelectedRecord, name string, fn func([]Value) (Value, error), vars ...Variable) (Value, error) {
+	args := make([]Value, len(vars))
+
+	for i, v := range vars {
+		value, err := v.Variable(record)
+		if err != nil || value == nil {
+			return nil, err
+		}
+
+		if kindOf(value) != kindNumber {
+			return nil, fmt.Errorf("querify: function %s expects numbers, got type '%s'", name, kindOf(value))
+		}
+
+		args[i] = value
+	}
+
+	return fn(args)
+}
+
+func integral(fn func(f float64) float64) func(args []Value) (Value, error) {
+	return func(args []Value) (Value, error) {
+		if i, ok := toInt(args[0]); ok {
+			return i, nil
+		}
+
+		f, _ := toFloat(args[0])
+
+		return fn(f), nil
+	}
+}
+
+func logarithm(f, base float64) (Value, error) {
+	switch {
+	case f == 0 || base == 0:
+		return nil, fmt.Errorf("querify: cannot take logarithm of zero")
+	case f < 0 || base < 0:
+		return nil, fmt.Errorf("querify: cannot take logarithm of a negative number")
+	case base == 1:
+		return nil, fmt.Errorf("querify: division by zero")
+	}
+
+	if base == 10 {
+		return math.Log10(f), nil
+	}
+
+	return math.Log(f) / math.Log(base), nil
+}
+
+// decimal rounds or truncates the decimal representation of a number, so that 2.675 rounds to 2.68 like in Postgres.
+func decimal(args []Value, trunc bool) (Value, error) {
+	places := 0
+
+	if len(args) > 1 {
+		p, ok := toInt(args[1])
+		if !ok {
+			f, _ := toFloat(args[1])
+			if f != math.Trunc(f) {
+				return nil, fmt.Errorf("querify: expected an integer, got '%v'", args[1])
+			}
+
+			p = int64(f)
+		}
+
+		places = int(p)
+	}
+
+	if i, ok := toInt(args[0]); ok && places >= 0 {
+		return i, nil
+	}
+
+	f, _ := toFloat(args[0])
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return f, nil
+	}
+
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		return nil, fmt.Errorf("querify: invalid number '%v'", f)
+	}
+
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(places))), nil))
+
+	if places >= 0 {
+		r.Mul(r, scale)
+	} else {
+		r.Quo(r, scale)
+	}
+
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+
+	if !trunc && new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+
+	r.SetInt(q)
+
+	if places >= 0 {
+		r.Quo(r, scale)
+	} else {
+		r.Mul(r, scale)
+	}
+
+	out, _ := r.Float64()
+
+	if _, ok := toInt(args[0]); ok {
+		return int64(out), nil
+	}
+
+	return out, nil
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+
+	return i
+}
@@ -0,0 +1,100 @@
+package querify_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestMath(t *testing.T) {
+	record := querify.SelectedRecord{Source: querify.Record{
+		Columns: []string{"int", "float", "null", "text"},
+		Values:  []querify.Value{-7, 2.675, nil, "a"},
+	}}
+
+	i, f, n := querify.Ident("int"), querify.Ident("float"), querify.Ident("null")
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+
+	tests := []struct {
+		variable querify.Variable
+		want     querify.Value
+	}{
+		{querify.Abs{i}, int64(7)},
+		{querify.Abs{lit(-1.5)}, 1.5},
+		{querify.Abs{n}, nil},
+		{querify.Round{Expression: f, Places: lit(2)}, 2.68},
+		{querify.Round{Expression: lit(-2.5)}, -3.0},
+		{querify.Round{Expression: lit(1250), Places: lit(-2)}, int64(1300)},
+		{querify.Round{Expression: i, Places: lit(1)}, int64(-7)},
+		{querify.Round{Expression: f, Places: n}, nil},
+		{querify.Trunc{Expression: f, Places: lit(2)}, 2.67},
+		{querify.Trunc{Expression: lit(-2.9)}, -2.0},
+		{querify.Ceil{f}, 3.0},
+		{querify.Floor{lit(-2.5)}, -3.0},
+		{querify.Ceil{i}, int64(-7)},
+		{querify.Sign{i}, int64(-1)},
+		{querify.Sign{lit(0.0)}, 0.0},
+		{querify.Power{lit(2), lit(10)}, 1024.0},
+		{querify.Sqrt{lit(16)}, 4.0},
+		{querify.Ln{lit(1)}, 0.0},
+		{querify.Log{Expression: lit(1000)}, 3.0},
+		{querify.Log{Expression: lit(8), Base: lit(2)}, 3.0},
+		{querify.Exp{lit(0)}, 1.0},
+		{querify.Mod{i, lit(3)}, int64(-1)},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Variable(record)
+		if err != nil {
+			t.Fatal(test.variable, err)
+		}
+
+		if got != test.want {
+			t.Fatalf("%v: %#v", test.variable, got)
+		}
+	}
+
+	for _, v := range []querify.Variable{
+		querify.Abs{querify.Ident("text")},
+		querify.Sqrt{i},
+		querify.Ln{lit(0)},
+		querify.Log{Expression: lit(8), Base: lit(1)},
+		querify.Power{lit(0), lit(-1)},
+		querify.Power{i, lit(0.5)},
+		querify.Round{Expression: f, Places: lit(1.5)},
+	} {
+		if _, err := v.Variable(record); err == nil {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestParseMath(t *testing.T) {
+	defer querify.SetRandomSource(rand.New(rand.NewSource(1)).Float64)()
+	want := rand.New(rand.NewSource(1)).Float64()
+
+	stmt, err := querify.Parse(`SELECT round(avg_id / 3.0, 2) AS rounded, abs(-id) AS abs, mod(id, 2) AS odd, `+
+		`power(id, 2) AS square, random() AS random `+
+		`FROM (SELECT id, id * 1.0 AS avg_id FROM users WHERE id = 2) AS u`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, []map[string]interface{}{
+		{"rounded": 0.67, "abs": 2.0, "odd": 0.0, "square": 4.0, "random": want},
+	}) {
+		t.Fatal(out)
+	}
+
+	defer querify.SetRandomSource(func() float64 { return 0.5 })()
+
+	roundTrip(t, stmt, tables())
+}
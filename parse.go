@@ -0,0 +1,2614 @@
+package querify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type SyntaxError struct {
+	Offset  int
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("querify: syntax error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+func newSyntaxError(input string, offset int, format string, args ...interface{}) *SyntaxError {
+	line, column := 1, 1
+
+	for _, r := range input[:offset] {
+		if r == '\n' {
+			line++
+			column = 1
+
+			continue
+		}
+
+		column++
+	}
+
+	return &SyntaxError{
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuoted
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of input"
+	}
+
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// symbols are matched in order, so longer symbols have to come first.
+var symbols = []string{
+	"!~*", "->>", "#>>", "->", "#>", "@>", "<@", "?|", "?&", "::", "<=", ">=", "<>", "!=", "!~", "~*", "||",
+	"(", ")", "[", "]", ",", ".", ";", "*", "+", "-", "/", "%", "=", "<", ">", "~", "?",
+}
+
+var reserved = map[string]bool{
+	"all": true, "and": true, "as": true, "asc": true, "between": true, "by": true, "case": true, "cast": true,
+	"cross": true, "desc": true, "distinct": true, "else": true, "end": true, "except": true,
+	"exists": true, "false": true, "filter": true, "from": true, "full": true, "group": true,
+	"having": true, "ilike": true, "in": true, "inner": true, "intersect": true, "is": true,
+	"join": true, "left": true, "like": true, "limit": true, "not": true, "null": true,
+	"nulls": true, "offset": true, "on": true, "or": true, "order": true, "outer": true,
+	"over": true, "right": true, "select": true, "then": true, "true": true, "union": true,
+	"when": true, "where": true, "window": true, "with": true, "within": true,
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(input); {
+		c := input[i]
+
+		switch {
+		case isSpace(c):
+			i++
+		case strings.HasPrefix(input[i:], "--"):
+			end := strings.IndexByte(input[i:], '\n')
+			if end < 0 {
+				end = len(input) - i
+			}
+
+			i += end
+		case strings.HasPrefix(input[i:], "/*"):
+			end := strings.Index(input[i+2:], "*/")
+			if end < 0 {
+				return nil, newSyntaxError(input, i, "unterminated comment")
+			}
+
+			i += end + 4
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(input) && isIdentPart(input[j]) {
+				j++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, text: input[i:j], offset: i})
+			i = j
+		case isDigit(c) || c == '.' && i+1 < len(input) && isDigit(input[i+1]):
+			j := lexNumber(input, i)
+
+			tokens = append(tokens, token{kind: tokenNumber, text: input[i:j], offset: i})
+			i = j
+		case c == '\'' || c == '"':
+			text, j, ok := lexQuoted(input, i)
+			if !ok {
+				if c == '"' {
+					return nil, newSyntaxError(input, i, "unterminated quoted identifier")
+				}
+
+				return nil, newSyntaxError(input, i, "unterminated string")
+			}
+
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuoted
+			}
+
+			tokens = append(tokens, token{kind: kind, text: text, offset: i})
+			i = j
+		default:
+			symbol := ""
+
+			for _, s := range symbols {
+				if strings.HasPrefix(input[i:], s) {
+					symbol = s
+
+					break
+				}
+			}
+
+			if symbol == "" {
+				return nil, newSyntaxError(input, i, "unexpected character '%c'", c)
+			}
+
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol, offset: i})
+			i += len(symbol)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, offset: len(input)}), nil
+}
+
+func lexNumber(input string, i int) int {
+	for i < len(input) && isDigit(input[i]) {
+		i++
+	}
+
+	if i < len(input) && input[i] == '.' {
+		i++
+
+		for i < len(input) && isDigit(input[i]) {
+			i++
+		}
+	}
+
+	if i < len(input) && (input[i] == 'e' || input[i] == 'E') {
+		j := i + 1
+		if j < len(input) && (input[j] == '+' || input[j] == '-') {
+			j++
+		}
+
+		if j < len(input) && isDigit(input[j]) {
+			for j < len(input) && isDigit(input[j]) {
+				j++
+			}
+
+			i = j
+		}
+	}
+
+	return i
+}
+
+func lexQuoted(input string, i int) (string, int, bool) {
+	quote := input[i]
+	b := strings.Builder{}
+
+	for j := i + 1; j < len(input); j++ {
+		if input[j] != quote {
+			b.WriteByte(input[j])
+
+			continue
+		}
+
+		if j+1 < len(input) && input[j+1] == quote {
+			b.WriteByte(quote)
+			j++
+
+			continue
+		}
+
+		return b.String(), j + 1, true
+	}
+
+	return "", 0, false
+}
+
+type expression interface {
+	Variable
+	Select
+}
+
+type call struct {
+	input    string
+	offset   int
+	name     string
+	distinct bool
+	star     bool
+	args     []expression
+	orderBy  []OrderBy
+	filter   Condition
+	group    []OrderBy
+}
+
+func (c call) errorf(format string, args ...interface{}) error {
+	return newSyntaxError(c.input, c.offset, format, args...)
+}
+
+func (c call) arity(min, max int) error {
+	if len(c.args) >= min && (max < 0 || len(c.args) <= max) {
+		return nil
+	}
+
+	switch {
+	case min == max:
+		return c.errorf("function %s expects %d argument(s)", c.name, min)
+	case max < 0:
+		return c.errorf("function %s expects at least %d argument(s)", c.name, min)
+	default:
+		return c.errorf("function %s expects %d to %d arguments", c.name, min, max)
+	}
+}
+
+func (c call) variables() []Variable {
+	vars := make([]Variable, len(c.args))
+
+	for i, a := range c.args {
+		vars[i] = a
+	}
+
+	return vars
+}
+
+// optional returns max arguments, missing optional arguments are nil.
+func (c call) optional(min, max int) ([]Variable, error) {
+	if err := c.arity(min, max); err != nil {
+		return make([]Variable, max), err
+	}
+
+	vars := make([]Variable, max)
+	copy(vars, c.variables())
+
+	return vars, nil
+}
+
+// field returns the first argument of functions like date_trunc, that expect a field name and a value.
+func (c call) field() (string, error) {
+	if err := c.arity(2, 2); err != nil {
+		return "", err
+	}
+
+	l, ok := c.args[0].(Literal)
+	if s, isString := l.Value.(string); ok && isString {
+		return strings.ToLower(s), nil
+	}
+
+	return "", c.errorf("function %s expects a field name", c.name)
+}
+
+func unary(build func(v Variable) interface{}) func(c call) (interface{}, error) {
+	return func(c call) (interface{}, error) {
+		if err := c.arity(1, 1); err != nil {
+			return nil, err
+		}
+
+		return build(c.args[0]), nil
+	}
+}
+
+func power(c call) (interface{}, error) {
+	if err := c.arity(2, 2); err != nil {
+		return nil, err
+	}
+
+	return Power{c.args[0], c.args[1]}, nil
+}
+
+func substring(c call) (interface{}, error) {
+	args, err := c.optional(2, 3)
+
+	return Substring{Expression: args[0], From: args[1], For: args[2]}, err
+}
+
+// sorted returns the expression of 'WITHIN GROUP (ORDER BY x)' and whether it is sorted descending.
+func (c call) sorted() (Select, bool, error) {
+	if len(c.group) == 1 {
+		switch o := c.group[0].(type) {
+		case Asc:
+			return o.Expression.(Select), false, nil
+		case Desc:
+			return o.Expression.(Select), true, nil
+		}
+	}
+
+	return nil, false, c.errorf("function %s expects a single expression in WITHIN GROUP", c.name)
+}
+
+func percentileCont(c call) (interface{}, error) {
+	expression, desc, err := c.sorted()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.arity(1, 1); err != nil {
+		return nil, err
+	}
+
+	return PercentileCont{Fraction: c.args[0], Expression: expression, Desc: desc, Filter: c.filter}, nil
+}
+
+func percentileDisc(c call) (interface{}, error) {
+	expression, desc, err := c.sorted()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.arity(1, 1); err != nil {
+		return nil, err
+	}
+
+	return PercentileDisc{Fraction: c.args[0], Expression: expression, Desc: desc, Filter: c.filter}, nil
+}
+
+// aggregation builds aggregates with a single argument.
+func aggregation(build func(c call) interface{}) func(c call) (interface{}, error) {
+	return func(c call) (interface{}, error) {
+		if err := c.arity(1, 1); err != nil {
+			return nil, err
+		}
+
+		return build(c), nil
+	}
+}
+
+func jsonAgg(c call) interface{} {
+	return JsonAgg{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+}
+
+func jsonObjectAgg(c call) (interface{}, error) {
+	if err := c.arity(2, 2); err != nil {
+		return nil, err
+	}
+
+	return JsonObjectAgg{Key: c.args[0], Value: c.args[1], Filter: c.filter, OrderBy: c.orderBy}, nil
+}
+
+type function struct {
+	aggregate bool
+	ordered   bool
+	window    bool
+	build     func(c call) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"count": {aggregate: true, build: func(c call) (interface{}, error) {
+		if c.star {
+			return CountAll{Filter: c.filter}, nil
+		}
+
+		if err := c.arity(1, 1); err != nil {
+			return nil, err
+		}
+
+		if ident, ok := c.args[0].(Ident); ok && !c.distinct && c.filter == nil && c.orderBy == nil {
+			return Count(ident), nil
+		}
+
+		return CountOf{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}, nil
+	}},
+	"array_agg": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return ArrayAgg{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"sum": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return Sum{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"avg": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return Avg{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"min": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return Min{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"max": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return Max{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"string_agg": {aggregate: true, build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return StringAgg{
+			Distinct:   c.distinct,
+			Expression: c.args[0],
+			Delimiter:  c.args[1],
+			Filter:     c.filter,
+			OrderBy:    c.orderBy,
+		}, nil
+	}},
+	"json_agg":         {aggregate: true, build: aggregation(jsonAgg)},
+	"jsonb_agg":        {aggregate: true, build: aggregation(jsonAgg)},
+	"json_object_agg":  {aggregate: true, build: jsonObjectAgg},
+	"jsonb_object_agg": {aggregate: true, build: jsonObjectAgg},
+	"bool_and": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return BoolAnd{Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"bool_or": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return BoolOr{Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"every": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return Every{Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"var_samp": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return VarSamp{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"variance": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return VarSamp{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"var_pop": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return VarPop{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"stddev_samp": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return StddevSamp{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"stddev": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return StddevSamp{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"stddev_pop": {aggregate: true, build: aggregation(func(c call) interface{} {
+		return StddevPop{Distinct: c.distinct, Expression: c.args[0], Filter: c.filter, OrderBy: c.orderBy}
+	})},
+	"percentile_cont": {aggregate: true, ordered: true, build: percentileCont},
+	"percentile_disc": {aggregate: true, ordered: true, build: percentileDisc},
+	"mode": {aggregate: true, ordered: true, build: func(c call) (interface{}, error) {
+		expression, desc, err := c.sorted()
+		if err != nil {
+			return nil, err
+		}
+
+		return Mode{Expression: expression, Desc: desc, Filter: c.filter}, c.arity(0, 0)
+	}},
+	"corr": {aggregate: true, build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return Corr{Y: c.args[0], X: c.args[1], Filter: c.filter, OrderBy: c.orderBy}, nil
+	}},
+	"covar_pop": {aggregate: true, build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return CovarPop{Y: c.args[0], X: c.args[1], Filter: c.filter, OrderBy: c.orderBy}, nil
+	}},
+	"regr_slope": {aggregate: true, build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return RegrSlope{Y: c.args[0], X: c.args[1], Filter: c.filter, OrderBy: c.orderBy}, nil
+	}},
+	"row_number": {window: true, build: func(c call) (interface{}, error) {
+		return RowNumber{}, c.arity(0, 0)
+	}},
+	"rank": {window: true, build: func(c call) (interface{}, error) {
+		return Rank{}, c.arity(0, 0)
+	}},
+	"dense_rank": {window: true, build: func(c call) (interface{}, error) {
+		return DenseRank{}, c.arity(0, 0)
+	}},
+	"ntile": {window: true, build: unary(func(v Variable) interface{} {
+		return Ntile{Buckets: v}
+	})},
+	"lag": {window: true, build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 3)
+
+		return Lag{Expression: args[0], Offset: args[1], Default: args[2]}, err
+	}},
+	"lead": {window: true, build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 3)
+
+		return Lead{Expression: args[0], Offset: args[1], Default: args[2]}, err
+	}},
+	"first_value": {window: true, build: unary(func(v Variable) interface{} {
+		return FirstValue{Expression: v}
+	})},
+	"last_value": {window: true, build: unary(func(v Variable) interface{} {
+		return LastValue{Expression: v}
+	})},
+	"nth_value": {window: true, build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return NthValue{Expression: c.args[0], N: c.args[1]}, nil
+	}},
+	"grouping": {build: func(c call) (interface{}, error) {
+		if err := c.arity(1, -1); err != nil {
+			return nil, err
+		}
+
+		columns := make(Grouping, len(c.args))
+
+		for i, a := range c.args {
+			ident, ok := a.(Ident)
+			if !ok {
+				return nil, c.errorf("function %s expects columns", c.name)
+			}
+
+			columns[i] = string(ident)
+		}
+
+		return columns, nil
+	}},
+	"concat": {build: func(c call) (interface{}, error) {
+		if err := c.arity(1, -1); err != nil {
+			return nil, err
+		}
+
+		return Concat(c.variables()), nil
+	}},
+	"coalesce": {build: func(c call) (interface{}, error) {
+		if err := c.arity(1, -1); err != nil {
+			return nil, err
+		}
+
+		return Coalesce(c.variables()), nil
+	}},
+	"nullif": {build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return NullIf{c.args[0], c.args[1]}, nil
+	}},
+	"greatest": {build: func(c call) (interface{}, error) {
+		if err := c.arity(1, -1); err != nil {
+			return nil, err
+		}
+
+		return Greatest(c.variables()), nil
+	}},
+	"least": {build: func(c call) (interface{}, error) {
+		if err := c.arity(1, -1); err != nil {
+			return nil, err
+		}
+
+		return Least(c.variables()), nil
+	}},
+	"lower":            {build: unary(func(v Variable) interface{} { return Lower{v} })},
+	"upper":            {build: unary(func(v Variable) interface{} { return Upper{v} })},
+	"length":           {build: unary(func(v Variable) interface{} { return Length{v} })},
+	"char_length":      {build: unary(func(v Variable) interface{} { return Length{v} })},
+	"character_length": {build: unary(func(v Variable) interface{} { return Length{v} })},
+	"btrim": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 2)
+
+		return Trim{Expression: args[0], Characters: args[1]}, err
+	}},
+	"ltrim": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 2)
+
+		return LTrim{Expression: args[0], Characters: args[1]}, err
+	}},
+	"rtrim": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 2)
+
+		return RTrim{Expression: args[0], Characters: args[1]}, err
+	}},
+	"substring": {build: substring},
+	"substr":    {build: substring},
+	"replace": {build: func(c call) (interface{}, error) {
+		if err := c.arity(3, 3); err != nil {
+			return nil, err
+		}
+
+		return Replace{c.args[0], c.args[1], c.args[2]}, nil
+	}},
+	"split_part": {build: func(c call) (interface{}, error) {
+		if err := c.arity(3, 3); err != nil {
+			return nil, err
+		}
+
+		return SplitPart{c.args[0], c.args[1], c.args[2]}, nil
+	}},
+	"strpos": {build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return Position{c.args[1], c.args[0]}, nil
+	}},
+	"left": {build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return Left{c.args[0], c.args[1]}, nil
+	}},
+	"right": {build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return Right{c.args[0], c.args[1]}, nil
+	}},
+	"lpad": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(2, 3)
+
+		return LPad{Expression: args[0], Length: args[1], Fill: args[2]}, err
+	}},
+	"rpad": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(2, 3)
+
+		return RPad{Expression: args[0], Length: args[1], Fill: args[2]}, err
+	}},
+	"regexp_replace": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(3, 4)
+
+		return RegexpReplace{Expression: args[0], Pattern: args[1], Replacement: args[2], Flags: args[3]}, err
+	}},
+	"regexp_matches": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(2, 3)
+
+		return RegexpMatches{Expression: args[0], Pattern: args[1], Flags: args[2]}, err
+	}},
+	"now": {build: func(c call) (interface{}, error) {
+		return Now{}, c.arity(0, 0)
+	}},
+	"date_trunc": {build: func(c call) (interface{}, error) {
+		field, err := c.field()
+		if err != nil {
+			return nil, err
+		}
+
+		return DateTrunc{Field: field, Expression: c.args[1]}, nil
+	}},
+	"date_part": {build: func(c call) (interface{}, error) {
+		field, err := c.field()
+		if err != nil {
+			return nil, err
+		}
+
+		return Extract{Field: field, Expression: c.args[1]}, nil
+	}},
+	"age": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 2)
+		if err != nil || args[1] == nil {
+			return Age{From: args[0]}, err
+		}
+
+		return Age{From: args[1], To: args[0]}, nil
+	}},
+	"abs":     {build: unary(func(v Variable) interface{} { return Abs{v} })},
+	"ceil":    {build: unary(func(v Variable) interface{} { return Ceil{v} })},
+	"ceiling": {build: unary(func(v Variable) interface{} { return Ceil{v} })},
+	"floor":   {build: unary(func(v Variable) interface{} { return Floor{v} })},
+	"sign":    {build: unary(func(v Variable) interface{} { return Sign{v} })},
+	"sqrt":    {build: unary(func(v Variable) interface{} { return Sqrt{v} })},
+	"ln":      {build: unary(func(v Variable) interface{} { return Ln{v} })},
+	"exp":     {build: unary(func(v Variable) interface{} { return Exp{v} })},
+	"round": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 2)
+
+		return Round{Expression: args[0], Places: args[1]}, err
+	}},
+	"trunc": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 2)
+
+		return Trunc{Expression: args[0], Places: args[1]}, err
+	}},
+	"log": {build: func(c call) (interface{}, error) {
+		args, err := c.optional(1, 2)
+		if err != nil || args[1] == nil {
+			return Log{Expression: args[0]}, err
+		}
+
+		return Log{Expression: args[1], Base: args[0]}, nil
+	}},
+	"gjson": {build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		l, ok := c.args[1].(Literal)
+		if path, isString := l.Value.(string); ok && isString {
+			return JsonQuery{Expression: c.args[0], Path: path}, nil
+		}
+
+		return nil, c.errorf("function %s expects a path", c.name)
+	}},
+	"power": {build: power},
+	"pow":   {build: power},
+	"mod": {build: func(c call) (interface{}, error) {
+		if err := c.arity(2, 2); err != nil {
+			return nil, err
+		}
+
+		return Mod{c.args[0], c.args[1]}, nil
+	}},
+	"random": {build: func(c call) (interface{}, error) {
+		return Random{}, c.arity(0, 0)
+	}},
+	"format": {build: func(c call) (interface{}, error) {
+		if err := c.arity(1, -1); err != nil {
+			return nil, err
+		}
+
+		return Format(c.variables()), nil
+	}},
+}
+
+var comparisons = map[string]func(left, right expression) Condition{
+	"=":   func(left, right expression) Condition { return Equals{left, right} },
+	"<>":  func(left, right expression) Condition { return NotEquals{left, right} },
+	"!=":  func(left, right expression) Condition { return NotEquals{left, right} },
+	"<":   func(left, right expression) Condition { return Less{left, right} },
+	"<=":  func(left, right expression) Condition { return LessOrEqual{left, right} },
+	">":   func(left, right expression) Condition { return Greater{left, right} },
+	">=":  func(left, right expression) Condition { return GreaterOrEqual{left, right} },
+	"~":   func(left, right expression) Condition { return Match{left, right} },
+	"~*":  func(left, right expression) Condition { return IMatch{left, right} },
+	"!~":  func(left, right expression) Condition { return NotMatch{left, right} },
+	"!~*": func(left, right expression) Condition { return NotIMatch{left, right} },
+	"@>":  func(left, right expression) Condition { return JsonContains{left, right} },
+	"<@":  func(left, right expression) Condition { return JsonContainedBy{left, right} },
+	"?":   func(left, right expression) Condition { return JsonHasKey{left, right} },
+	"?|":  func(left, right expression) Condition { return JsonHasAnyKey{left, right} },
+	"?&":  func(left, right expression) Condition { return JsonHasAllKeys{left, right} },
+}
+
+// operators bind weaker than arithmetics and are left-associative.
+var operators = map[string]func(left, right expression) expression{
+	"||": func(left, right expression) expression {
+		if c, ok := left.(Concat); ok {
+			return append(c[:len(c):len(c)], right)
+		}
+
+		return Concat{left, right}
+	},
+	"->":  func(left, right expression) expression { return JsonGet{left, right} },
+	"->>": func(left, right expression) expression { return JsonGetText{left, right} },
+	"#>":  func(left, right expression) expression { return JsonPath{left, right} },
+	"#>>": func(left, right expression) expression { return JsonPathText{left, right} },
+}
+
+// arithmetics are grouped by precedence, from the lowest to the highest.
+var arithmetics = []map[string]func(left, right expression) expression{
+	{
+		"+": func(left, right expression) expression { return Add{left, right} },
+		"-": func(left, right expression) expression { return Sub{left, right} },
+	},
+	{
+		"*": func(left, right expression) expression { return Mul{left, right} },
+		"/": func(left, right expression) expression { return Div{left, right} },
+		"%": func(left, right expression) expression { return Mod{left, right} },
+	},
+}
+
+type parser struct {
+	input     string
+	tokens    []token
+	index     int
+	tables    map[string]Query
+	aggregate bool
+}
+
+func Parse(sql string, tables map[string]Query) (Statement, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	p := &parser{input: sql, tokens: tokens, tables: tables}
+
+	stmt, err := p.parseStatement()
+	if err != nil {
+		return Statement{}, err
+	}
+
+	p.acceptSymbol(";")
+
+	if p.peek().kind != tokenEOF {
+		return Statement{}, p.unexpected()
+	}
+
+	return stmt, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.index]
+}
+
+func (p *parser) peekAt(n int) token {
+	if p.index+n < len(p.tokens) {
+		return p.tokens[p.index+n]
+	}
+
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.index]
+
+	if tok.kind != tokenEOF {
+		p.index++
+	}
+
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return newSyntaxError(p.input, tok.offset, format, args...)
+}
+
+func (p *parser) unexpected() error {
+	return p.errorf(p.peek(), "unexpected %s", p.peek())
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	tok := p.peek()
+
+	return tok.kind == tokenIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *parser) acceptKeyword(keyword string) bool {
+	if p.isKeyword(keyword) {
+		p.next()
+
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.acceptKeyword(keyword) {
+		return p.errorf(p.peek(), "expected %s, got %s", strings.ToUpper(keyword), p.peek())
+	}
+
+	return nil
+}
+
+func (p *parser) isSymbol(symbol string) bool {
+	tok := p.peek()
+
+	return tok.kind == tokenSymbol && tok.text == symbol
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if p.isSymbol(symbol) {
+		p.next()
+
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return p.errorf(p.peek(), "expected '%s', got %s", symbol, p.peek())
+	}
+
+	return nil
+}
+
+func (p *parser) expression(tok token, node interface{}) (expression, error) {
+	expr, ok := node.(expression)
+	if !ok {
+		return nil, p.errorf(tok, "expected an expression")
+	}
+
+	return expr, nil
+}
+
+func (p *parser) condition(tok token, node interface{}) (Condition, error) {
+	cond, ok := node.(Condition)
+	if !ok {
+		return nil, p.errorf(tok, "expected a condition")
+	}
+
+	return cond, nil
+}
+
+func (p *parser) parseName() (string, error) {
+	tok := p.peek()
+
+	switch {
+	case tok.kind == tokenQuoted:
+		p.next()
+
+		return tok.text, nil
+	case tok.kind == tokenIdent && !reserved[strings.ToLower(tok.text)]:
+		p.next()
+
+		return strings.ToLower(tok.text), nil
+	}
+
+	return "", p.errorf(tok, "expected an identifier, got %s", tok)
+}
+
+func (p *parser) parseColumn() (Ident, error) {
+	name, err := p.parseName()
+	if err != nil {
+		return "", err
+	}
+
+	for p.acceptSymbol(".") {
+		part, err := p.parseName()
+		if err != nil {
+			return "", err
+		}
+
+		name += "." + part
+	}
+
+	return Ident(name), nil
+}
+
+func (p *parser) parseAlias() (string, bool, error) {
+	if p.acceptKeyword("as") {
+		tok := p.peek()
+
+		switch tok.kind {
+		case tokenQuoted:
+			p.next()
+
+			return tok.text, true, nil
+		case tokenIdent:
+			p.next()
+
+			return strings.ToLower(tok.text), true, nil
+		}
+
+		return "", false, p.errorf(tok, "expected an alias, got %s", tok)
+	}
+
+	tok := p.peek()
+	if tok.kind == tokenQuoted || tok.kind == tokenIdent && !reserved[strings.ToLower(tok.text)] {
+		name, err := p.parseName()
+
+		return name, true, err
+	}
+
+	return "", false, nil
+}
+
+func (p *parser) parseUint() (uint64, error) {
+	tok := p.peek()
+	if tok.kind != tokenNumber {
+		return 0, p.errorf(tok, "expected a number, got %s", tok)
+	}
+
+	n, err := strconv.ParseUint(tok.text, 10, 64)
+	if err != nil {
+		return 0, p.errorf(tok, "invalid number %s", tok)
+	}
+
+	p.next()
+
+	return n, nil
+}
+
+func (p *parser) parseStatement() (Statement, error) {
+	outer := p.aggregate
+	p.aggregate = false
+
+	defer func() {
+		p.aggregate = outer
+	}()
+
+	var (
+		stmt Statement
+		err  error
+	)
+
+	if err = p.expectKeyword("select"); err != nil {
+		return Statement{}, err
+	}
+
+	stmt.Distinct = p.acceptKeyword("distinct")
+	if !stmt.Distinct {
+		p.acceptKeyword("all")
+	}
+
+	if stmt.Select, err = p.parseSelectList(); err != nil {
+		return Statement{}, err
+	}
+
+	if err = p.expectKeyword("from"); err != nil {
+		return Statement{}, err
+	}
+
+	if stmt.From, err = p.parseFromItem(); err != nil {
+		return Statement{}, err
+	}
+
+	for {
+		join, ok, err := p.parseJoin()
+		if err != nil {
+			return Statement{}, err
+		}
+
+		if !ok {
+			break
+		}
+
+		stmt.Joins = append(stmt.Joins, join)
+	}
+
+	if p.acceptKeyword("where") {
+		if stmt.Where, err = p.parseCondition(); err != nil {
+			return Statement{}, err
+		}
+	}
+
+	if p.acceptKeyword("group") {
+		if err = p.expectKeyword("by"); err != nil {
+			return Statement{}, err
+		}
+
+		if stmt.GroupBy, err = p.parseGroupBy(); err != nil {
+			return Statement{}, err
+		}
+	}
+
+	if p.acceptKeyword("having") {
+		if stmt.Having, err = p.parseCondition(); err != nil {
+			return Statement{}, err
+		}
+	}
+
+	if p.acceptKeyword("order") {
+		if err = p.expectKeyword("by"); err != nil {
+			return Statement{}, err
+		}
+
+		if stmt.OrderBy, err = p.parseOrderBy(); err != nil {
+			return Statement{}, err
+		}
+	}
+
+	if err = p.parseLimitOffset(&stmt); err != nil {
+		return Statement{}, err
+	}
+
+	if len(stmt.GroupBy) == 0 && (p.aggregate || stmt.Having != nil) {
+		stmt.GroupBy = []GroupBy{GroupingSets{{}}}
+	}
+
+	return stmt, nil
+}
+
+func (p *parser) parseLimitOffset(stmt *Statement) error {
+	for {
+		switch {
+		case stmt.Limit == nil && p.acceptKeyword("limit"):
+			if p.acceptKeyword("all") {
+				continue
+			}
+
+			n, err := p.parseUint()
+			if err != nil {
+				return err
+			}
+
+			stmt.Limit = &n
+		case stmt.Offset == nil && p.acceptKeyword("offset"):
+			n, err := p.parseUint()
+			if err != nil {
+				return err
+			}
+
+			if !p.acceptKeyword("rows") {
+				p.acceptKeyword("row")
+			}
+
+			stmt.Offset = &n
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseSelectList() ([]Select, error) {
+	if p.acceptSymbol("*") {
+		return nil, nil
+	}
+
+	var selects []Select
+
+	for {
+		tok := p.peek()
+
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		expr, err := p.expression(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok, err := p.parseAlias()
+		if err != nil {
+			return nil, err
+		}
+
+		if ident, isIdent := expr.(Ident); isIdent && !ok {
+			name, ok = string(ident[strings.LastIndex(string(ident), ".")+1:]), true
+		}
+
+		if ok {
+			selects = append(selects, As{Name: name, Expression: expr})
+		} else {
+			selects = append(selects, expr)
+		}
+
+		if !p.acceptSymbol(",") {
+			return selects, nil
+		}
+	}
+}
+
+func (p *parser) parseFromItem() (Query, error) {
+	tok := p.peek()
+
+	if p.acceptSymbol("(") {
+		sub, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		alias, _, err := p.parseAlias()
+		if err != nil {
+			return nil, err
+		}
+
+		return source{query: sub, alias: alias}, nil
+	}
+
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+
+	query, ok := p.tables[name]
+	if !ok {
+		return nil, p.errorf(tok, "table '%s' not found", name)
+	}
+
+	alias, ok, err := p.parseAlias()
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		alias = name
+	}
+
+	return source{query: query, name: name, alias: alias}, nil
+}
+
+func (p *parser) parseJoin() (Join, bool, error) {
+	if p.acceptSymbol(",") {
+		right, err := p.parseFromItem()
+		if err != nil {
+			return nil, false, err
+		}
+
+		return CrossJoin{Right: right}, true, nil
+	}
+
+	kind := ""
+
+	switch {
+	case p.acceptKeyword("cross"):
+		kind = "cross"
+	case p.acceptKeyword("inner"), p.isKeyword("join"):
+		kind = "inner"
+	case p.acceptKeyword("left"):
+		kind = "left"
+	case p.acceptKeyword("right"):
+		kind = "right"
+	case p.acceptKeyword("full"):
+		kind = "full"
+	default:
+		return nil, false, nil
+	}
+
+	if kind == "left" || kind == "right" || kind == "full" {
+		p.acceptKeyword("outer")
+	}
+
+	if err := p.expectKeyword("join"); err != nil {
+		return nil, false, err
+	}
+
+	right, err := p.parseFromItem()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if kind == "cross" {
+		return CrossJoin{Right: right}, true, nil
+	}
+
+	if err = p.expectKeyword("on"); err != nil {
+		return nil, false, err
+	}
+
+	on, err := p.parseCondition()
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch kind {
+	case "left":
+		return LeftJoin{Right: right, On: on}, true, nil
+	case "right":
+		return RightJoin{Right: right, On: on}, true, nil
+	case "full":
+		return FullJoin{Right: right, On: on}, true, nil
+	}
+
+	return InnerJoin{Right: right, On: on}, true, nil
+}
+
+func (p *parser) parseGroupBy() ([]GroupBy, error) {
+	var groups []GroupBy
+
+	for {
+		group, err := p.parseGroupingElement()
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, group)
+
+		if !p.acceptSymbol(",") {
+			return groups, nil
+		}
+	}
+}
+
+func (p *parser) parseGroupingElement() (GroupBy, error) {
+	switch {
+	case p.acceptKeyword("cube"):
+		set, err := p.parseGroupingSet()
+		if err != nil {
+			return nil, err
+		}
+
+		return Cube(set), nil
+	case p.acceptKeyword("rollup"):
+		set, err := p.parseGroupingSet()
+		if err != nil {
+			return nil, err
+		}
+
+		return Rollup(set), nil
+	case p.isKeyword("grouping") && strings.EqualFold(p.peekAt(1).text, "sets"):
+		p.next()
+		p.next()
+
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+
+		var sets GroupingSets
+
+		for {
+			set, err := p.parseGroupingSet()
+			if err != nil {
+				return nil, err
+			}
+
+			sets = append(sets, set)
+
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		return sets, nil
+	case p.isSymbol("("):
+		set, err := p.parseGroupingSet()
+		if err != nil {
+			return nil, err
+		}
+
+		return GroupingSets{set}, nil
+	}
+
+	return p.parseColumn()
+}
+
+func (p *parser) parseGroupingSet() ([]string, error) {
+	if !p.acceptSymbol("(") {
+		column, err := p.parseColumn()
+
+		return []string{string(column)}, err
+	}
+
+	set := []string{}
+
+	if p.acceptSymbol(")") {
+		return set, nil
+	}
+
+	for {
+		column, err := p.parseColumn()
+		if err != nil {
+			return nil, err
+		}
+
+		set = append(set, string(column))
+
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+
+	return set, p.expectSymbol(")")
+}
+
+func (p *parser) parseOrderBy() ([]OrderBy, error) {
+	var orders []OrderBy
+
+	for {
+		tok := p.peek()
+
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		expr, err := p.expression(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		desc := p.acceptKeyword("desc")
+		if !desc {
+			p.acceptKeyword("asc")
+		}
+
+		nullsLast := !desc
+
+		if p.acceptKeyword("nulls") {
+			switch {
+			case p.acceptKeyword("first"):
+				nullsLast = false
+			case p.acceptKeyword("last"):
+				nullsLast = true
+			default:
+				return nil, p.errorf(p.peek(), "expected FIRST or LAST, got %s", p.peek())
+			}
+		}
+
+		if desc {
+			orders = append(orders, Desc{Expression: expr, NullsLast: nullsLast})
+		} else {
+			orders = append(orders, Asc{Expression: expr, NullsLast: nullsLast})
+		}
+
+		if !p.acceptSymbol(",") {
+			return orders, nil
+		}
+	}
+}
+
+func (p *parser) parseCondition() (Condition, error) {
+	tok := p.peek()
+
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.condition(tok, node)
+}
+
+func (p *parser) parseExpression() (expression, error) {
+	tok := p.peek()
+
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.expression(tok, node)
+}
+
+func (p *parser) parseExpr() (interface{}, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (interface{}, error) {
+	tok := p.peek()
+
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptKeyword("or") {
+		left, err := p.condition(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		rtok := p.peek()
+
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := p.condition(rtok, next)
+		if err != nil {
+			return nil, err
+		}
+
+		node = Or{left, right}
+	}
+
+	return node, nil
+}
+
+func (p *parser) parseAnd() (interface{}, error) {
+	tok := p.peek()
+
+	node, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isKeyword("and") {
+		return node, nil
+	}
+
+	first, err := p.condition(tok, node)
+	if err != nil {
+		return nil, err
+	}
+
+	and := And{first}
+
+	for p.acceptKeyword("and") {
+		rtok := p.peek()
+
+		next, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		cond, err := p.condition(rtok, next)
+		if err != nil {
+			return nil, err
+		}
+
+		and = append(and, cond)
+	}
+
+	return and, nil
+}
+
+func (p *parser) parseNot() (interface{}, error) {
+	if !p.isKeyword("not") {
+		return p.parseComparison()
+	}
+
+	p.next()
+
+	if p.isKeyword("exists") {
+		node, err := p.parseExists(true)
+		if err != nil {
+			return nil, err
+		}
+
+		return node, nil
+	}
+
+	tok := p.peek()
+
+	node, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	cond, err := p.condition(tok, node)
+	if err != nil {
+		return nil, err
+	}
+
+	return Not{cond}, nil
+}
+
+func (p *parser) parseComparison() (interface{}, error) {
+	tok := p.peek()
+
+	node, err := p.parseOperator()
+	if err != nil {
+		return nil, err
+	}
+
+	not := p.isKeyword("not")
+
+	predicate := p.peek()
+	if not {
+		predicate = p.peekAt(1)
+	}
+
+	if keyword := strings.ToLower(predicate.text); predicate.kind == tokenIdent &&
+		(keyword == "in" || keyword == "between" || keyword == "like" || keyword == "ilike" ||
+			!not && keyword == "is") {
+		left, err := p.expression(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		return p.parsePredicate(left)
+	}
+
+	op := p.peek()
+
+	build, ok := comparisons[op.text]
+	if op.kind != tokenSymbol || !ok {
+		return node, nil
+	}
+
+	p.next()
+
+	left, err := p.expression(tok, node)
+	if err != nil {
+		return nil, err
+	}
+
+	rtok := p.peek()
+
+	next, err := p.parseOperator()
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := p.expression(rtok, next)
+	if err != nil {
+		return nil, err
+	}
+
+	return build(left, right), nil
+}
+
+func (p *parser) parsePredicate(left expression) (interface{}, error) {
+	if p.isKeyword("not") && !strings.EqualFold(p.peekAt(1).text, "in") {
+		p.next()
+
+		node, err := p.parsePredicate(left)
+		if err != nil {
+			return nil, err
+		}
+
+		return Not{node.(Condition)}, nil
+	}
+
+	switch {
+	case p.isKeyword("between"):
+		return p.parseBetween(left)
+	case p.isKeyword("is"):
+		return p.parseIs(left)
+	case p.isKeyword("like"), p.isKeyword("ilike"):
+		return p.parseLike(left)
+	}
+
+	return p.parseIn(left)
+}
+
+func (p *parser) parseOperand() (expression, error) {
+	tok := p.peek()
+
+	node, err := p.parseOperator()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.expression(tok, node)
+}
+
+func (p *parser) parseBetween(left expression) (interface{}, error) {
+	p.next()
+
+	low, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectKeyword("and"); err != nil {
+		return nil, err
+	}
+
+	high, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return Between{Expression: left, Low: low, High: high}, nil
+}
+
+func (p *parser) parseLike(left expression) (interface{}, error) {
+	op := p.next()
+
+	pattern, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	var escape Variable
+
+	if p.acceptKeyword("escape") {
+		escape, err = p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.EqualFold(op.text, "ilike") {
+		return ILike{Expression: left, Pattern: pattern, Escape: escape}, nil
+	}
+
+	return Like{Expression: left, Pattern: pattern, Escape: escape}, nil
+}
+
+func (p *parser) parseIs(left expression) (interface{}, error) {
+	p.next()
+
+	not := p.acceptKeyword("not")
+
+	if p.acceptKeyword("null") {
+		if not {
+			return IsNotNull{left}, nil
+		}
+
+		return IsNull{left}, nil
+	}
+
+	if err := p.expectKeyword("distinct"); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeyword("from"); err != nil {
+		return nil, err
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if not {
+		return IsNotDistinctFrom{left, right}, nil
+	}
+
+	return IsDistinctFrom{left, right}, nil
+}
+
+func (p *parser) parseIn(left expression) (interface{}, error) {
+	not := p.acceptKeyword("not")
+
+	if err := p.expectKeyword("in"); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	if p.isKeyword("select") {
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		if not {
+			return NotInQuery{Expression: left, Query: stmt}, nil
+		}
+
+		return InQuery{Expression: left, Query: stmt}, nil
+	}
+
+	var list []Variable
+
+	for {
+		tok := p.peek()
+
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		expr, err := p.expression(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, expr)
+
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	if not {
+		return NotIn{Expression: left, List: list}, nil
+	}
+
+	return In{Expression: left, List: list}, nil
+}
+
+func (p *parser) parseOperator() (interface{}, error) {
+	tok := p.peek()
+
+	node, err := p.parseArithmetic(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+
+		build, ok := operators[op.text]
+		if op.kind != tokenSymbol || !ok {
+			return node, nil
+		}
+
+		p.next()
+
+		left, err := p.expression(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		rtok := p.peek()
+
+		next, err := p.parseArithmetic(0)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := p.expression(rtok, next)
+		if err != nil {
+			return nil, err
+		}
+
+		node = build(left, right)
+	}
+}
+
+func (p *parser) parseArithmetic(level int) (interface{}, error) {
+	if level == len(arithmetics) {
+		return p.parseUnary()
+	}
+
+	tok := p.peek()
+
+	node, err := p.parseArithmetic(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+
+		build, ok := arithmetics[level][op.text]
+		if op.kind != tokenSymbol || !ok {
+			return node, nil
+		}
+
+		p.next()
+
+		left, err := p.expression(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		rtok := p.peek()
+
+		next, err := p.parseArithmetic(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := p.expression(rtok, next)
+		if err != nil {
+			return nil, err
+		}
+
+		node = build(left, right)
+	}
+}
+
+func (p *parser) parseUnary() (interface{}, error) {
+	switch {
+	case p.isSymbol("-") && p.peekAt(1).kind == tokenNumber:
+		p.next()
+
+		node, err := p.parseNumber("-")
+		if err != nil {
+			return nil, err
+		}
+
+		return p.parsePostfix(node)
+	case p.acceptSymbol("-"):
+		tok := p.peek()
+
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		expr, err := p.expression(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		return Neg{expr}, nil
+	case p.acceptSymbol("+"):
+		return p.parseUnary()
+	}
+
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parsePostfix(node)
+}
+
+func (p *parser) parsePostfix(node interface{}) (interface{}, error) {
+	for p.isSymbol("::") {
+		tok := p.next()
+
+		expr, err := p.expression(tok, node)
+		if err != nil {
+			return nil, err
+		}
+
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+
+		node = Cast{Expression: expr, Type: typ}
+	}
+
+	return node, nil
+}
+
+func (p *parser) parseType() (string, error) {
+	tok := p.peek()
+	if tok.kind != tokenIdent {
+		return "", p.errorf(tok, "expected a type, got %s", tok)
+	}
+
+	p.next()
+
+	name := strings.ToLower(tok.text)
+
+	for p.peek().kind == tokenIdent {
+		next := name + " " + strings.ToLower(p.peek().text)
+
+		prefix := false
+
+		for t := range casts {
+			prefix = prefix || t == next || strings.HasPrefix(t, next+" ")
+		}
+
+		if !prefix {
+			break
+		}
+
+		p.next()
+
+		name = next
+	}
+
+	if p.acceptSymbol("(") {
+		var modifiers []string
+
+		for {
+			mod := p.peek()
+			if mod.kind != tokenNumber {
+				return "", p.errorf(mod, "expected a number, got %s", mod)
+			}
+
+			p.next()
+
+			modifiers = append(modifiers, mod.text)
+
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+
+		if err := p.expectSymbol(")"); err != nil {
+			return "", err
+		}
+
+		name += "(" + strings.Join(modifiers, ",") + ")"
+	}
+
+	for p.acceptSymbol("[") {
+		if err := p.expectSymbol("]"); err != nil {
+			return "", err
+		}
+
+		name += "[]"
+	}
+
+	if err := checkType(name); err != nil {
+		return "", p.errorf(tok, "%s", err)
+	}
+
+	return name, nil
+}
+
+func (p *parser) parsePosition() (interface{}, error) {
+	p.next()
+	p.next()
+
+	sub, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectKeyword("in"); err != nil {
+		return nil, err
+	}
+
+	str, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	return Position{sub, str}, nil
+}
+
+// parseSubstring parses substring(x FROM start FOR count) and the function call syntax substring(x, start, count).
+func (p *parser) parseSubstring() (interface{}, error) {
+	p.next()
+	p.next()
+
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	s := Substring{Expression: expr, From: Literal{Value: int64(1)}}
+
+	switch {
+	case p.acceptSymbol(","):
+		if s.From, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+
+		if p.acceptSymbol(",") {
+			if s.For, err = p.parseExpression(); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		if p.acceptKeyword("from") {
+			if s.From, err = p.parseExpression(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.acceptKeyword("for") {
+			if s.For, err = p.parseExpression(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// parseTrim parses trim([LEADING | TRAILING | BOTH] [characters] FROM x) and trim(x [, characters]).
+func (p *parser) parseTrim() (interface{}, error) {
+	p.next()
+	p.next()
+
+	mode := "both"
+
+	for _, m := range []string{"leading", "trailing", "both"} {
+		if p.acceptKeyword(m) {
+			mode = m
+		}
+	}
+
+	var (
+		expr, chars Variable
+		err         error
+	)
+
+	if !p.acceptKeyword("from") {
+		if expr, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+
+		if p.acceptKeyword("from") {
+			chars = expr
+		}
+	}
+
+	if expr == nil || chars != nil {
+		if expr, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+	} else if p.acceptSymbol(",") {
+		if chars, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case "leading":
+		return LTrim{Expression: expr, Characters: chars}, nil
+	case "trailing":
+		return RTrim{Expression: expr, Characters: chars}, nil
+	}
+
+	return Trim{Expression: expr, Characters: chars}, nil
+}
+
+func (p *parser) parseExtract() (interface{}, error) {
+	p.next()
+	p.next()
+
+	tok := p.next()
+	if tok.kind != tokenIdent && tok.kind != tokenString {
+		return nil, p.errorf(tok, "expected a field, got %s", tok)
+	}
+
+	if err := p.expectKeyword("from"); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	return Extract{Field: strings.ToLower(tok.text), Expression: expr}, nil
+}
+
+// parseTypedLiteral parses literals like DATE '2021-01-01' or INTERVAL '7 days' as casts.
+func (p *parser) parseTypedLiteral() (interface{}, error) {
+	typ := strings.ToLower(p.next().text)
+	tok := p.next()
+
+	if _, err := convert(tok.text, typ); err != nil {
+		return nil, p.errorf(tok, "invalid %s %s", typ, tok)
+	}
+
+	return Cast{Expression: Literal{Value: tok.text}, Type: typ}, nil
+}
+
+func (p *parser) parseCast() (interface{}, error) {
+	p.next()
+
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectKeyword("as"); err != nil {
+		return nil, err
+	}
+
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	return Cast{Expression: expr, Type: typ}, nil
+}
+
+func (p *parser) parsePrimary() (interface{}, error) {
+	tok := p.peek()
+
+	switch tok.kind {
+	case tokenNumber:
+		return p.parseNumber("")
+	case tokenString:
+		p.next()
+
+		return Literal{Value: tok.text}, nil
+	case tokenQuoted:
+		return p.parseColumn()
+	case tokenIdent:
+		switch strings.ToLower(tok.text) {
+		case "null":
+			p.next()
+
+			return Literal{}, nil
+		case "true", "false":
+			p.next()
+
+			return Literal{Value: strings.EqualFold(tok.text, "true")}, nil
+		case "exists":
+			return p.parseExists(false)
+		case "case":
+			return p.parseCase()
+		case "cast":
+			return p.parseCast()
+		case "current_timestamp":
+			p.next()
+
+			return Now{}, nil
+		case "current_date":
+			p.next()
+
+			return Cast{Expression: Now{}, Type: "date"}, nil
+		case "date", "timestamp", "timestamptz", "interval":
+			if p.peekAt(1).kind == tokenString {
+				return p.parseTypedLiteral()
+			}
+		}
+
+		if p.peekAt(1).kind == tokenSymbol && p.peekAt(1).text == "(" {
+			switch name := strings.ToLower(tok.text); {
+			case name == "position":
+				return p.parsePosition()
+			case name == "substring":
+				return p.parseSubstring()
+			case name == "trim":
+				return p.parseTrim()
+			case name == "extract":
+				return p.parseExtract()
+			case name == "left", name == "right", !reserved[name]:
+				return p.parseCall()
+			}
+		}
+
+		return p.parseColumn()
+	case tokenSymbol:
+		if p.acceptSymbol("(") {
+			node, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			return node, p.expectSymbol(")")
+		}
+	}
+
+	return nil, p.unexpected()
+}
+
+func (p *parser) parseExists(not bool) (interface{}, error) {
+	p.next()
+
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	stmt, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	if not {
+		return NotExists{Query: stmt}, nil
+	}
+
+	return Exists{Query: stmt}, nil
+}
+
+func (p *parser) parseCase() (interface{}, error) {
+	p.next()
+
+	var operand expression
+
+	if !p.isKeyword("when") {
+		var err error
+
+		operand, err = p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := Case{}
+
+	for p.acceptKeyword("when") {
+		var (
+			when Condition
+			err  error
+		)
+
+		if operand != nil {
+			var right expression
+
+			right, err = p.parseExpression()
+			when = Equals{operand, right}
+		} else {
+			when, err = p.parseCondition()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = p.expectKeyword("then"); err != nil {
+			return nil, err
+		}
+
+		then, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		c.When = append(c.When, WhenThen{When: when, Then: then})
+	}
+
+	if len(c.When) == 0 {
+		return nil, p.errorf(p.peek(), "expected WHEN, got %s", p.peek())
+	}
+
+	if p.acceptKeyword("else") {
+		e, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		c.Else = e
+	}
+
+	if err := p.expectKeyword("end"); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (p *parser) parseNumber(sign string) (interface{}, error) {
+	tok := p.next()
+
+	if n, err := strconv.ParseInt(sign+tok.text, 10, 64); err == nil {
+		return Literal{Value: n}, nil
+	}
+
+	f, err := strconv.ParseFloat(sign+tok.text, 64)
+	if err != nil {
+		return nil, p.errorf(tok, "invalid number %s", tok)
+	}
+
+	return Literal{Value: f}, nil
+}
+
+func (p *parser) parseCall() (interface{}, error) {
+	tok := p.next()
+	p.next()
+
+	c := call{input: p.input, offset: tok.offset, name: strings.ToLower(tok.text)}
+
+	fn, ok := functions[c.name]
+	if !ok {
+		return nil, p.errorf(tok, "function %s does not exist", c.name)
+	}
+
+	if p.acceptSymbol("*") {
+		c.star = true
+	} else if !p.isSymbol(")") {
+		c.distinct = p.acceptKeyword("distinct")
+		if !c.distinct {
+			p.acceptKeyword("all")
+		}
+
+		for {
+			atok := p.peek()
+
+			node, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			arg, err := p.expression(atok, node)
+			if err != nil {
+				return nil, err
+			}
+
+			c.args = append(c.args, arg)
+
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+
+		if p.acceptKeyword("order") {
+			if err := p.expectKeyword("by"); err != nil {
+				return nil, err
+			}
+
+			orderBy, err := p.parseOrderBy()
+			if err != nil {
+				return nil, err
+			}
+
+			c.orderBy = orderBy
+		}
+	}
+
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	if p.acceptKeyword("within") {
+		if err := p.expectKeyword("group"); err != nil {
+			return nil, err
+		}
+
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+
+		if err := p.expectKeyword("order"); err != nil {
+			return nil, err
+		}
+
+		if err := p.expectKeyword("by"); err != nil {
+			return nil, err
+		}
+
+		group, err := p.parseOrderBy()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		c.group = group
+	}
+
+	if fn.ordered != (c.group != nil) {
+		if fn.ordered {
+			return nil, p.errorf(tok, "function %s requires WITHIN GROUP", c.name)
+		}
+
+		return nil, p.errorf(tok, "function %s is not an ordered-set aggregate", c.name)
+	}
+
+	if p.acceptKeyword("filter") {
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+
+		if err := p.expectKeyword("where"); err != nil {
+			return nil, err
+		}
+
+		filter, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		c.filter = filter
+	}
+
+	if !fn.aggregate && (c.star || c.distinct || c.orderBy != nil || c.filter != nil) {
+		return nil, p.errorf(tok, "function %s is not an aggregate", c.name)
+	}
+
+	if !p.acceptKeyword("over") {
+		if fn.window {
+			return nil, p.errorf(tok, "window function %s requires OVER", c.name)
+		}
+
+		if fn.aggregate {
+			p.aggregate = true
+		}
+
+		return fn.build(c)
+	}
+
+	if !fn.window && !fn.aggregate {
+		return nil, p.errorf(tok, "function %s is not a window function", c.name)
+	}
+
+	over, err := p.parseWindow()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := fn.build(c)
+	if err != nil {
+		return nil, err
+	}
+
+	over.Function = node.(Variable)
+
+	return over, nil
+}
+
+// parseWindow parses '(PARTITION BY a ORDER BY b ROWS BETWEEN 1 PRECEDING AND CURRENT ROW)' after OVER.
+func (p *parser) parseWindow() (Over, error) {
+	var over Over
+
+	if err := p.expectSymbol("("); err != nil {
+		return over, err
+	}
+
+	if p.acceptKeyword("partition") {
+		if err := p.expectKeyword("by"); err != nil {
+			return over, err
+		}
+
+		for {
+			tok := p.peek()
+
+			node, err := p.parseExpr()
+			if err != nil {
+				return over, err
+			}
+
+			expr, err := p.expression(tok, node)
+			if err != nil {
+				return over, err
+			}
+
+			over.PartitionBy = append(over.PartitionBy, expr)
+
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	if p.acceptKeyword("order") {
+		if err := p.expectKeyword("by"); err != nil {
+			return over, err
+		}
+
+		orderBy, err := p.parseOrderBy()
+		if err != nil {
+			return over, err
+		}
+
+		over.OrderBy = orderBy
+	}
+
+	if p.isKeyword("rows") || p.isKeyword("range") {
+		frame, err := p.parseFrame(strings.EqualFold(p.next().text, "range"))
+		if err != nil {
+			return over, err
+		}
+
+		over.Frame = &frame
+	}
+
+	return over, p.expectSymbol(")")
+}
+
+func (p *parser) parseFrame(isRange bool) (Frame, error) {
+	frame := Frame{Range: isRange}
+
+	if !p.acceptKeyword("between") {
+		start, err := p.parseBound()
+
+		frame.Start = start
+
+		return frame, err
+	}
+
+	start, err := p.parseBound()
+	if err != nil {
+		return frame, err
+	}
+
+	if err = p.expectKeyword("and"); err != nil {
+		return frame, err
+	}
+
+	end, err := p.parseBound()
+
+	frame.Start, frame.End = start, end
+
+	return frame, err
+}
+
+func (p *parser) parseBound() (Bound, error) {
+	switch {
+	case p.acceptKeyword("unbounded"):
+		if p.acceptKeyword("following") {
+			return Bound{Unbounded: true, Following: true}, nil
+		}
+
+		return Bound{Unbounded: true}, p.expectKeyword("preceding")
+	case p.acceptKeyword("current"):
+		return Bound{}, p.expectKeyword("row")
+	}
+
+	tok := p.peek()
+
+	node, err := p.parseOperator()
+	if err != nil {
+		return Bound{}, err
+	}
+
+	offset, err := p.expression(tok, node)
+	if err != nil {
+		return Bound{}, err
+	}
+
+	if p.acceptKeyword("following") {
+		return Bound{Offset: offset, Following: true}, nil
+	}
+
+	return Bound{Offset: offset}, p.expectKeyword("preceding")
+}
@@ -0,0 +1,132 @@
+package querify_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func tables() map[string]querify.Query {
+	return map[string]querify.Query{
+		"hobbies": querify.From([]map[string]interface{}{
+			{"id": 1, "name": "Football"},
+			{"id": 2, "name": "Basketball"},
+			{"id": 3, "name": "Hockey"},
+		}),
+		"users": querify.From([]map[string]interface{}{
+			{"id": 1, "name": "Max"},
+			{"id": 2, "name": "Tom"},
+			{"id": 3, "name": "Alex"},
+		}),
+		"user_hobbies": querify.From([]map[string]interface{}{
+			{"user_id": 1, "hobby_id": 1},
+			{"user_id": 1, "hobby_id": 2},
+			{"user_id": 2, "hobby_id": 3},
+			{"user_id": 3, "hobby_id": 1},
+		}),
+	}
+}
+
+func TestParse(t *testing.T) {
+	type User struct {
+		Name    string
+		Hobbies []string
+	}
+
+	stmt, err := querify.Parse(`
+		SELECT u.name, array_agg(h.name) AS hobbies
+		FROM users u
+		LEFT JOIN user_hobbies uh ON u.id = uh.user_id
+		LEFT JOIN hobbies h ON h.id = uh.hobby_id
+		WHERE u.id < 3 OR u.name = 'Alex'
+		GROUP BY u.name
+		HAVING count(*) > 0
+		ORDER BY u.name DESC
+		LIMIT 2 OFFSET 1`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users []User
+
+	err = stmt.Execute().Scan(&users)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []User{
+		{Name: "Max", Hobbies: []string{"Football", "Basketball"}},
+		{Name: "Alex", Hobbies: []string{"Football"}},
+	}
+
+	if !reflect.DeepEqual(users, want) {
+		t.Fatal(users)
+	}
+}
+
+func TestParseAggregate(t *testing.T) {
+	var out []struct {
+		Count int
+		Tag   string
+	}
+
+	stmt, err := querify.Parse(`SELECT count(*), '#' || count(*) AS tag FROM user_hobbies WHERE hobby_id = 1`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stmt.Scan(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 1 || out[0].Count != 2 || out[0].Tag != "#2" {
+		t.Fatal(out)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := querify.Parse("SELECT name\nFROM users\nWHERE name = 'Max", tables())
+
+	var syntaxErr *querify.SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 3 || syntaxErr.Column != 14 {
+		t.Fatal(err)
+	}
+
+	_, err = querify.Parse("SELECT name FROM unknown", tables())
+	if !errors.As(err, &syntaxErr) || syntaxErr.Column != 18 {
+		t.Fatal(err)
+	}
+}
+
+type mutableQuery struct {
+	table querify.Table
+}
+
+func (m *mutableQuery) Query() querify.Table {
+	return m.table
+}
+
+func TestParseExecuteLatestData(t *testing.T) {
+	users := &mutableQuery{table: querify.From([]map[string]interface{}{{"id": 1, "name": "Max"}})}
+
+	stmt, err := querify.Parse(`SELECT s.name FROM (SELECT name FROM users u WHERE u.id > 0) AS s`,
+		map[string]querify.Query{"users": users})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users.table = querify.From([]map[string]interface{}{{"id": 1, "name": "Max"}, {"id": 2, "name": "Tom"}})
+
+	var names []string
+
+	if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"Max", "Tom"}) {
+		t.Fatal(names)
+	}
+}
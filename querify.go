@@ -4,16 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tidwall/gjson"
 )
 
-const (
-	null = "null"
-)
-
 type Value interface{}
 
 func Copy(value Value) (Value, error) {
@@ -144,6 +140,13 @@ type Table struct {
 	Err     error
 	Columns []string
 	Data    [][]Value
+
+	name      string
+	alias     string
+	grouping  []string
+	groupBy   []string
+	groups    []Table
+	statement *Statement
 }
 
 func (t Table) Copy() Table {
@@ -164,8 +167,10 @@ func (t Table) Copy() Table {
 	}
 
 	return Table{
-		Columns: append([]string{}, t.Columns...),
-		Data:    data,
+		Columns:  append([]string{}, t.Columns...),
+		Data:     data,
+		grouping: t.grouping,
+		groupBy:  t.groupBy,
 	}
 }
 
@@ -174,12 +179,22 @@ func (t Table) As(name string) Table {
 		return Table{Err: t.Err}
 	}
 
+	columns := make([]string, len(t.Columns))
+
 	for i, c := range t.Columns {
 		if name == "" {
-			t.Columns[i] = c
+			columns[i] = c
 			continue
 		}
-		t.Columns[i] = name + "." + c[strings.LastIndex(c, ".")+1:]
+		columns[i] = name + "." + c[strings.LastIndex(c, ".")+1:]
+	}
+
+	t.Columns = columns
+
+	if t.name == "" && t.statement == nil {
+		t.name = name
+	} else {
+		t.alias = name
 	}
 
 	return t
@@ -287,6 +302,14 @@ func (t Table) Query() Table {
 	return t
 }
 
+func (t Table) SQL() (string, error) {
+	if t.Err != nil {
+		return "", t.Err
+	}
+
+	return t.trace(stageLimit).SQL()
+}
+
 func (t Table) Record(index int) Record {
 	if t.Err != nil {
 		return Record{Err: t.Err}
@@ -334,10 +357,13 @@ func (t Table) UnionAll(table Table) Table {
 }
 
 func (t Table) Join(joins ...Join) Table {
-	if t.Err != nil {
+	if t.Err != nil || len(joins) == 0 {
 		return t
 	}
 
+	s := t.trace(stageJoin)
+	s.Joins = append(s.Joins, joins...)
+
 	for _, j := range joins {
 		t = j.Join(t)
 		if t.Err != nil {
@@ -345,6 +371,8 @@ func (t Table) Join(joins ...Join) Table {
 		}
 	}
 
+	t.name, t.alias, t.statement = "", "", &s
+
 	return t
 }
 
@@ -353,21 +381,29 @@ func (t Table) Where(condition Condition) Table {
 		return Table{Err: t.Err}
 	}
 
-	n := 0
+	data := make([][]Value, 0, len(t.Data))
+	prepared := prepare(condition)
 
 	for _, d := range t.Data {
-		keep, err := condition.Condition(GroupedRecord{Source: Record{Columns: t.Columns, Values: d}})
+		keep, err := prepared.Condition(GroupedRecord{Source: Record{Columns: t.Columns, Values: d}})
 		if err != nil {
 			return Table{Err: err}
 		}
 
 		if keep {
-			t.Data[n] = d
-			n++
+			data = append(data, d)
 		}
 	}
 
-	t.Data = t.Data[:n]
+	s := t.trace(stageWhere)
+	if s.Where != nil {
+		s.Where = And{s.Where, condition}
+	} else {
+		s.Where = condition
+	}
+
+	t.Data = data
+	t.name, t.alias, t.statement = "", "", &s
 
 	return t
 }
@@ -411,7 +447,24 @@ func (t Table) GroupBy(groups ...GroupBy) GroupedTable {
 		}
 	}
 
-	return GroupedTable{Source: union.Source, Grouped: union.Grouped}
+	var columns []string
+
+	for _, set := range groupingSets {
+		for _, c := range set {
+			if !grouped(columns, c) {
+				columns = append(columns, c)
+			}
+		}
+	}
+
+	for i := range union.Grouped {
+		union.Grouped[i].groupBy = columns
+	}
+
+	s := t.trace(stageGroupBy)
+	s.GroupBy = append(s.GroupBy, groups...)
+
+	return GroupedTable{Source: union.Source, Grouped: union.Grouped, statement: &s}
 }
 
 func (t Table) Select(selects ...Select) SelectedTable {
@@ -496,15 +549,29 @@ func (t Table) Delete(where Condition) Table {
 }
 
 type GroupedRecord struct {
-	Err     error
-	Source  Record
-	Grouped Table
+	Err      error
+	Source   Record
+	Grouped  Table
+	Selected Record
+	Outer    *GroupedRecord
+
+	now time.Time
+}
+
+func (r GroupedRecord) selected() SelectedRecord {
+	return SelectedRecord{
+		Err: r.Err, Source: r.Source, Grouped: r.Grouped, Selected: r.Selected, Outer: r.Outer, now: r.now,
+	}
 }
 
 type GroupedTable struct {
 	Err     error
 	Source  Table
 	Grouped []Table
+
+	outer     *GroupedRecord
+	now       time.Time
+	statement *Statement
 }
 
 func (t GroupedTable) Copy() GroupedTable {
@@ -569,7 +636,10 @@ func (t GroupedTable) Having(condition Condition) GroupedTable {
 		return GroupedTable{Err: t.Err}
 	}
 
-	n := 0
+	data := make([][]Value, 0, len(t.Source.Data))
+	groups := make([]Table, 0, len(t.Grouped))
+
+	prepared := prepare(condition)
 
 	for i, d := range t.Source.Data {
 		var grouped Table
@@ -577,7 +647,7 @@ func (t GroupedTable) Having(condition Condition) GroupedTable {
 			grouped = t.Grouped[i]
 		}
 
-		keep, err := condition.Condition(
+		keep, err := prepared.Condition(
 			GroupedRecord{
 				Source:  Record{Columns: t.Source.Columns, Values: d},
 				Grouped: grouped})
@@ -586,22 +656,25 @@ func (t GroupedTable) Having(condition Condition) GroupedTable {
 		}
 
 		if keep {
-			t.Source.Data[n] = d
+			data = append(data, d)
 
-			if n < len(t.Grouped) {
-				t.Grouped[n] = grouped
+			if i < len(t.Grouped) {
+				groups = append(groups, grouped)
 			}
-
-			n++
 		}
 	}
 
-	t.Source.Data = t.Source.Data[:n]
-
-	if n < len(t.Grouped) {
-		t.Grouped = t.Grouped[:n]
+	s := t.trace(stageHaving)
+	if s.Having != nil {
+		s.Having = And{s.Having, condition}
+	} else {
+		s.Having = condition
 	}
 
+	t.Source.Data = data
+	t.Grouped = groups
+	t.statement = &s
+
 	return t
 }
 
@@ -610,11 +683,16 @@ func (t GroupedTable) Select(selects ...Select) SelectedTable {
 		return SelectedTable{Err: t.Err}
 	}
 
+	s := t.trace(stageSelect)
+	s.Select = selects
+
 	if len(selects) == 0 {
 		return SelectedTable{
-			Source:   t.Source,
-			Grouped:  t.Grouped,
-			Selected: t.Source,
+			Source:    t.Source,
+			Grouped:   t.Grouped,
+			Selected:  t.Source,
+			now:       t.now,
+			statement: &s,
 		}
 	}
 
@@ -626,7 +704,7 @@ func (t GroupedTable) Select(selects ...Select) SelectedTable {
 	data := make([][]Value, len(t.Source.Data))
 
 	for i, s := range selects {
-		col, values, err := s.Select(SelectedTable{Source: t.Source, Grouped: t.Grouped})
+		col, values, err := s.Select(SelectedTable{Source: t.Source, Grouped: t.Grouped, outer: t.outer, now: t.now})
 		if err != nil {
 			return SelectedTable{Err: err}
 		}
@@ -648,14 +726,38 @@ func (t GroupedTable) Select(selects ...Select) SelectedTable {
 			Columns: cols,
 			Data:    data,
 		},
+		now:       t.now,
+		statement: &s,
 	}
 }
 
+func (t GroupedTable) SQL() (string, error) {
+	if t.Err != nil {
+		return "", t.Err
+	}
+
+	return t.trace(stageLimit).SQL()
+}
+
 type SelectedRecord struct {
 	Err      error
 	Source   Record
 	Grouped  Table
 	Selected Record
+	Outer    *GroupedRecord
+
+	now time.Time
+}
+
+// rows returns the rows of the group. Window frames over grouped tables keep their groups.
+func (r SelectedRecord) rows() SelectedTable {
+	return SelectedTable{Source: r.Grouped, Grouped: r.Grouped.groups, outer: r.Outer, now: r.now}
+}
+
+func (r SelectedRecord) grouped() GroupedRecord {
+	return GroupedRecord{
+		Err: r.Err, Source: r.Source, Grouped: r.Grouped, Selected: r.Selected, Outer: r.Outer, now: r.now,
+	}
 }
 
 type SelectedTable struct {
@@ -663,6 +765,10 @@ type SelectedTable struct {
 	Source   Table
 	Grouped  []Table
 	Selected Table
+
+	outer     *GroupedRecord
+	now       time.Time
+	statement *Statement
 }
 
 func (t SelectedTable) Record(index int) SelectedRecord {
@@ -673,7 +779,9 @@ func (t SelectedTable) Record(index int) SelectedRecord {
 		Selected: Record{
 			Columns: t.Selected.Columns,
 		},
-		Err: t.Err,
+		Outer: t.outer,
+		Err:   t.Err,
+		now:   t.now,
 	}
 
 	if index < len(t.Source.Data) {
@@ -696,36 +804,41 @@ func (t SelectedTable) Distinct() SelectedTable {
 		return SelectedTable{Err: t.Err}
 	}
 
-	distinct := map[string][]Value{}
+	distinct := map[string]bool{}
+	out := SelectedTable{
+		Source:   Table{Columns: t.Source.Columns},
+		Selected: Table{Columns: t.Selected.Columns},
+		now:      t.now,
+	}
 
-	for _, d := range t.Selected.Data {
+	for i, d := range t.Selected.Data {
 		b, err := json.Marshal(d)
 		if err != nil {
 			return SelectedTable{Err: err}
 		}
 
-		if _, ok := distinct[string(b)]; ok {
+		if distinct[string(b)] {
 			continue
 		}
 
-		distinct[string(b)] = d
-	}
+		distinct[string(b)] = true
 
-	n := 0
+		out.Selected.Data = append(out.Selected.Data, d)
 
-	for _, r := range distinct {
-		if n < len(t.Selected.Data) {
-			t.Selected.Data[n] = r
+		if i < len(t.Source.Data) {
+			out.Source.Data = append(out.Source.Data, t.Source.Data[i])
 		}
 
-		n++
+		if i < len(t.Grouped) {
+			out.Grouped = append(out.Grouped, t.Grouped[i])
+		}
 	}
 
-	if n < len(t.Selected.Data) {
-		t.Selected.Data = t.Selected.Data[:n]
-	}
+	s := t.trace(stageDistinct)
+	s.Distinct = true
+	out.statement = &s
 
-	return t
+	return out
 }
 
 func (t SelectedTable) OrderBy(orders ...OrderBy) SelectedTable {
@@ -733,7 +846,7 @@ func (t SelectedTable) OrderBy(orders ...OrderBy) SelectedTable {
 		return SelectedTable{Err: t.Err}
 	}
 
-	qt := SelectedTable{Source: t.Source, Selected: t.Selected, Grouped: t.Grouped}
+	qt := SelectedTable{Source: t.Source, Selected: t.Selected, Grouped: t.Grouped, now: t.now}
 
 	arr := make([]SelectedRecord, len(t.Selected.Data))
 
@@ -744,7 +857,7 @@ func (t SelectedTable) OrderBy(orders ...OrderBy) SelectedTable {
 	var err error
 	var comp int
 
-	sort.Slice(arr, func(i, j int) bool {
+	sort.SliceStable(arr, func(i, j int) bool {
 		if err != nil {
 			return false
 		}
@@ -779,8 +892,12 @@ func (t SelectedTable) OrderBy(orders ...OrderBy) SelectedTable {
 		table.Selected.Data[i] = r.Selected.Values
 	}
 
+	s := t.trace(stageOrderBy)
+	s.OrderBy = append(append([]OrderBy{}, orders...), s.OrderBy...)
+
 	table.Source = Table{}
 	table.Grouped = nil
+	table.statement = &s
 
 	return table
 }
@@ -790,8 +907,14 @@ func (t SelectedTable) Limit(limit uint64) SelectedTable {
 		return SelectedTable{Err: t.Err}
 	}
 
+	s := t.trace(stageLimit)
+	if s.Limit == nil || *s.Limit > limit {
+		s.Limit = &limit
+	}
+
 	t.Source = Table{}
 	t.Grouped = nil
+	t.statement = &s
 
 	if t.Err != nil {
 		return t
@@ -811,15 +934,33 @@ func (t SelectedTable) Offset(offset uint64) SelectedTable {
 		return SelectedTable{Err: t.Err}
 	}
 
+	s := t.trace(stageLimit)
+	if s.Limit != nil {
+		limit := *s.Limit - offset
+		if offset > *s.Limit {
+			limit = 0
+		}
+
+		s.Limit = &limit
+	}
+
+	total := offset
+	if s.Offset != nil {
+		total += *s.Offset
+	}
+
+	s.Offset = &total
+
 	t.Source = Table{}
 	t.Grouped = nil
+	t.statement = &s
 
 	if t.Err != nil {
 		return t
 	}
 
 	if int(offset) > len(t.Selected.Data) {
-		return SelectedTable{Selected: Table{Columns: append([]string{}, t.Selected.Columns...)}}
+		return SelectedTable{Selected: Table{Columns: append([]string{}, t.Selected.Columns...)}, statement: &s}
 	}
 
 	t.Selected.Data = t.Selected.Data[offset:]
@@ -833,11 +974,20 @@ func (t SelectedTable) Query() Table {
 	}
 
 	return Table{
-		Columns: t.Selected.Columns,
-		Data:    t.Selected.Data,
+		Columns:   t.Selected.Columns,
+		Data:      t.Selected.Data,
+		statement: t.statement,
 	}
 }
 
+func (t SelectedTable) SQL() (string, error) {
+	if t.Err != nil {
+		return "", t.Err
+	}
+
+	return t.trace(stageLimit).SQL()
+}
+
 func (t SelectedTable) Scan(dest interface{}) error {
 	if t.Err != nil {
 		return t.Err
@@ -883,8 +1033,9 @@ func groupByColumns(table Table, columns []string) GroupedTable {
 			},
 			Grouped: []Table{
 				{
-					Columns: table.Columns,
-					Data:    table.Data,
+					Columns:  table.Columns,
+					Data:     table.Data,
+					grouping: []string{},
 				},
 			},
 		}
@@ -892,22 +1043,30 @@ func groupByColumns(table Table, columns []string) GroupedTable {
 
 	out := GroupedTable{
 		Source: Table{
-			Columns: append([]string{}, columns...),
+			Columns: make([]string, len(columns)),
 			Data:    [][]Value{},
 		},
 		Grouped: []Table{},
 	}
 
+	values := make([][]Value, len(columns))
+
+	for j, c := range columns {
+		col, vals, err := Ident(c).Select(SelectedTable{Source: table})
+		if err != nil {
+			return GroupedTable{Err: err}
+		}
+
+		out.Source.Columns[j] = col
+		values[j] = vals
+	}
+
 	m := map[string]int{}
 
-	for _, d := range table.Data {
+	for i, d := range table.Data {
 		unique := make([]Value, len(columns))
-		for j, c := range columns {
-			v, err := Ident(c).Variable(SelectedRecord{Source: Record{Columns: table.Columns, Values: d}})
-			if err != nil {
-				return GroupedTable{Err: err}
-			}
-			unique[j] = v
+		for j := range columns {
+			unique[j] = values[j][i]
 		}
 
 		b, err := json.Marshal(unique)
@@ -919,7 +1078,7 @@ func groupByColumns(table Table, columns []string) GroupedTable {
 		if !ok {
 			m[string(b)] = len(out.Source.Data)
 			out.Source.Data = append(out.Source.Data, unique)
-			out.Grouped = append(out.Grouped, Table{Columns: table.Columns, Data: [][]Value{d}})
+			out.Grouped = append(out.Grouped, Table{Columns: table.Columns, Data: [][]Value{d}, grouping: columns})
 		} else {
 			out.Grouped[position].Data = append(out.Grouped[position].Data, d)
 		}
@@ -940,42 +1099,38 @@ func (l Literal) Select(table SelectedTable) (string, []Value, error) {
 	out := make([]Value, len(table.Source.Data))
 
 	for i := range out {
-		out[i] = l
+		out[i] = l.Value
 	}
 
 	return "literal", out, nil
 }
 
+func (l Literal) SQL() (string, error) {
+	return renderValue(l.Value)
+}
+
 type Ident string
 
 func (i Ident) Variable(record SelectedRecord) (Value, error) {
 	source := record.Source
 
-	index := -1
-
-	for j, c := range record.Source.Columns {
-		if c == string(i) || c[:strings.LastIndex(c, ".")+1] == string(i) {
-			if index != -1 {
-				return nil, fmt.Errorf("querify: ident '%s' is ambiguous", i)
-			}
-
-			index = j
-		}
+	index, err := i.index(record.Source.Columns)
+	if err != nil {
+		return nil, err
 	}
 
 	if index < 0 {
 		source = record.Selected
-		for j, c := range record.Selected.Columns {
-			if c == string(i) || c[:strings.LastIndex(c, ".")+1] == string(i) {
-				if index != -1 {
-					return nil, fmt.Errorf("querify: ident '%s' is ambiguous", i)
-				}
 
-				index = j
-			}
+		if index, err = i.index(record.Selected.Columns); err != nil {
+			return nil, err
 		}
 	}
 
+	if index < 0 && record.Outer != nil {
+		return i.Variable(record.Outer.selected())
+	}
+
 	if index < 0 {
 		return nil, fmt.Errorf("querify: ident '%s' not found", i)
 	}
@@ -990,31 +1145,23 @@ func (i Ident) Variable(record SelectedRecord) (Value, error) {
 func (i Ident) Select(table SelectedTable) (string, []Value, error) {
 	source := table.Source
 
-	index := -1
-
-	for j, c := range table.Source.Columns {
-		if c == string(i) || c[:strings.LastIndex(c, ".")+1] == string(i) {
-			if index != -1 {
-				return "", nil, fmt.Errorf("querify: ident '%s' is ambiguous", i)
-			}
-
-			index = j
-		}
+	index, err := i.index(table.Source.Columns)
+	if err != nil {
+		return "", nil, err
 	}
 
 	if index < 0 {
 		source = table.Selected
-		for j, c := range table.Selected.Columns {
-			if c == string(i) || c[:strings.LastIndex(c, ".")+1] == string(i) {
-				if index != -1 {
-					return "", nil, fmt.Errorf("querify: ident '%s' is ambiguous", i)
-				}
 
-				index = j
-			}
+		if index, err = i.index(table.Selected.Columns); err != nil {
+			return "", nil, err
 		}
 	}
 
+	if index < 0 && table.outer != nil {
+		return selectVariable(string(i), i, table)
+	}
+
 	if index < 0 {
 		return "", nil, fmt.Errorf("querify: ident '%s' not found", i)
 	}
@@ -1030,10 +1177,57 @@ func (i Ident) Select(table SelectedTable) (string, []Value, error) {
 	return source.Columns[index], values, nil
 }
 
+// index returns the index of the column matching the ident, or -1. Exact names are preferred.
+func (i Ident) index(columns []string) (int, error) {
+	for _, match := range []func(column string) bool{i.exact, i.unqualified} {
+		index := -1
+
+		for j, c := range columns {
+			if !match(c) {
+				continue
+			}
+
+			if index != -1 {
+				return -1, fmt.Errorf("querify: ident '%s' is ambiguous", i)
+			}
+
+			index = j
+		}
+
+		if index >= 0 {
+			return index, nil
+		}
+	}
+
+	return -1, nil
+}
+
+func (i Ident) exact(column string) bool {
+	return column == string(i) || column[:strings.LastIndex(column, ".")+1] == string(i)
+}
+
+func (i Ident) unqualified(column string) bool {
+	return !strings.Contains(string(i), ".") && column[strings.LastIndex(column, ".")+1:] == string(i)
+}
+
+func (i Ident) match(column string) bool {
+	return i.exact(column) || i.unqualified(column)
+}
+
 func (i Ident) GroupBy() (GroupingSets, error) {
 	return [][]string{{string(i)}}, nil
 }
 
+func (i Ident) SQL() (string, error) {
+	parts := strings.Split(string(i), ".")
+
+	for j, p := range parts {
+		parts[j] = quoteIdent(p)
+	}
+
+	return strings.Join(parts, "."), nil
+}
+
 type Concat []Variable
 
 func (c Concat) Variable(record SelectedRecord) (Value, error) {
@@ -1045,17 +1239,22 @@ func (c Concat) Variable(record SelectedRecord) (Value, error) {
 			return nil, err
 		}
 
-		js, err := json.Marshal(value)
-		if err != nil {
-			return nil, err
+		if value == nil {
+			continue
 		}
 
-		s, err := strconv.Unquote(string(js))
+		if s, ok := value.(string); ok {
+			b.WriteString(s)
+
+			continue
+		}
+
+		js, err := json.Marshal(value)
 		if err != nil {
 			return nil, err
 		}
 
-		b.WriteString(s)
+		b.Write(js)
 	}
 
 	return b.String(), nil
@@ -1076,26 +1275,71 @@ func (c Concat) Select(table SelectedTable) (string, []Value, error) {
 	return "concat", out, nil
 }
 
-type CountAll struct{}
+func (c Concat) SQL() (string, error) {
+	return renderCall("concat", c...)
+}
+
+// CountAll counts the rows of a group like 'count(*)'.
+type CountAll struct {
+	Filter Condition
+}
+
+func (c CountAll) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, c.Filter, nil)
+	if err != nil {
+		return nil, err
+	}
 
-func (CountAll) Variable(record SelectedRecord) (Value, error) {
 	return len(record.Grouped.Data), nil
 }
 
-func (CountAll) Select(table SelectedTable) (string, []Value, error) {
-	out := make([]Value, len(table.Grouped))
+func (c CountAll) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("count", c, table)
+}
+
+func (c CountAll) SQL() (string, error) {
+	if c.Filter == nil {
+		return "count(*)", nil
+	}
 
-	for i, g := range table.Grouped {
-		out[i] = len(g.Data)
+	cond, err := render(c.Filter)
+	if err != nil {
+		return "", err
 	}
 
-	return "count", out, nil
+	return "count(*) FILTER (WHERE " + cond + ")", nil
 }
 
+// Count counts the values of a column, that are not NULL, like 'count(x)'. Use CountOf to filter or order them.
 type Count string
 
 func (c Count) Variable(record SelectedRecord) (Value, error) {
-	_, values, err := Ident(c).Select(SelectedTable{Source: record.Grouped})
+	return CountOf{Expression: Ident(c)}.Variable(record)
+}
+
+func (c Count) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("count", c, table)
+}
+
+func (c Count) SQL() (string, error) {
+	return renderCall("count", Ident(c))
+}
+
+// CountOf counts the values of an expression, that are not NULL.
+type CountOf struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (c CountOf) Variable(record SelectedRecord) (Value, error) {
+	record, err := within(record, c.Filter, c.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := aggregate(record, c.Distinct, c.Expression)
 	if err != nil {
 		return nil, err
 	}
@@ -1103,27 +1347,29 @@ func (c Count) Variable(record SelectedRecord) (Value, error) {
 	return len(values), nil
 }
 
-func (c Count) Select(table SelectedTable) (string, []Value, error) {
-	out := make([]Value, len(table.Grouped))
-
-	for i, g := range table.Grouped {
-		_, values, err := Ident(c).Select(SelectedTable{Source: g})
-		if err != nil {
-			return "", nil, err
-		}
-		out[i] = len(values)
-	}
+func (c CountOf) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("count", c, table)
+}
 
-	return "count", out, nil
+func (c CountOf) SQL() (string, error) {
+	return renderAggregate("count", c.Distinct, c.OrderBy, c.Filter, c.Expression)
 }
 
+// ArrayAgg collects all values including NULLs. Distinct keeps the first of equal values.
 type ArrayAgg struct {
 	Distinct   bool
 	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
 }
 
 func (a ArrayAgg) Variable(record SelectedRecord) (Value, error) {
-	_, values, err := a.Expression.Select(SelectedTable{Source: record.Grouped})
+	record, err := within(record, a.Filter, a.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	_, values, err := a.Expression.Select(record.rows())
 	if err != nil {
 		return nil, err
 	}
@@ -1133,47 +1379,18 @@ func (a ArrayAgg) Variable(record SelectedRecord) (Value, error) {
 	}
 
 	if a.Distinct {
-		unique := map[string]Value{}
-
-		for _, v := range values {
-			b, err := json.Marshal(v)
-			if err != nil {
-				return nil, err
-			}
-
-			if _, ok := unique[string(b)]; !ok {
-				unique[string(b)] = v
-			}
-		}
-
-		out := make([]Value, len(unique))
-
-		n := 0
-
-		for _, v := range unique {
-			out[n] = v
-			n++
-		}
-
-		return out, nil
+		return unique(values)
 	}
 
 	return values, nil
 }
 
 func (a ArrayAgg) Select(table SelectedTable) (string, []Value, error) {
-	values := make([]Value, len(table.Grouped))
-
-	for i, g := range table.Grouped {
-		value, err := a.Variable(SelectedRecord{Grouped: g})
-		if err != nil {
-			return "", nil, err
-		}
-
-		values[i] = value
-	}
+	return selectAggregate("array_agg", a, table)
+}
 
-	return "array_agg", values, nil
+func (a ArrayAgg) SQL() (string, error) {
+	return renderAggregate("array_agg", a.Distinct, a.OrderBy, a.Filter, a.Expression)
 }
 
 type As struct {
@@ -1186,6 +1403,19 @@ func (a As) Select(table SelectedTable) (string, []Value, error) {
 	return a.Name, values, err
 }
 
+func (a As) SQL() (string, error) {
+	expr, err := render(a.Expression)
+	if err != nil {
+		return "", err
+	}
+
+	if i, ok := a.Expression.(Ident); ok && string(i[strings.LastIndex(string(i), ".")+1:]) == a.Name {
+		return expr, nil
+	}
+
+	return expr + " AS " + quoteIdent(a.Name), nil
+}
+
 type GroupingSets [][]string
 
 func (gs GroupingSets) GroupBy() (GroupingSets, error) {
@@ -1205,6 +1435,26 @@ func (gs GroupingSets) Cart(bb GroupingSets) GroupingSets {
 	return p
 }
 
+func (gs GroupingSets) SQL() (string, error) {
+	sets := make([]string, len(gs))
+
+	for i, set := range gs {
+		columns := make([]string, len(set))
+
+		for j, c := range set {
+			columns[j], _ = Ident(c).SQL()
+		}
+
+		sets[i] = "(" + strings.Join(columns, ", ") + ")"
+	}
+
+	if len(sets) == 1 {
+		return sets[0], nil
+	}
+
+	return "GROUPING SETS (" + strings.Join(sets, ", ") + ")", nil
+}
+
 type Cube []string
 
 func (c Cube) GroupBy() (GroupingSets, error) {
@@ -1228,138 +1478,13 @@ func (c Cube) GroupBy() (GroupingSets, error) {
 	return subsets, nil
 }
 
-type And []Condition
-
-func (a And) Condition(record GroupedRecord) (bool, error) {
-	for _, c := range a {
-		keep, err := c.Condition(record)
-		if err != nil {
-			return false, err
-		}
-
-		if !keep {
-			return false, nil
-		}
-	}
-
-	return true, nil
-}
-
-type Or [2]Condition
-
-func (o Or) Condition(record GroupedRecord) (bool, error) {
-	for _, c := range o {
-		keep, err := c.Condition(record)
-		if err != nil {
-			return false, err
-		}
-
-		if keep {
-			return true, nil
-		}
-	}
-
-	return false, nil
-}
-
-type Equals [2]Variable
-
-func (e Equals) Condition(record GroupedRecord) (bool, error) {
-	v0, err := e[0].Variable(SelectedRecord{Source: record.Source, Grouped: record.Grouped})
+func (c Cube) SQL() (string, error) {
+	set, err := GroupingSets{c}.SQL()
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
-	b0, err := json.Marshal(v0)
-	if err != nil {
-		return false, err
-	}
-
-	v1, err := e[1].Variable(SelectedRecord{Source: record.Source, Grouped: record.Grouped})
-	if err != nil {
-		return false, err
-	}
-
-	b1, err := json.Marshal(v1)
-	if err != nil {
-		return false, err
-	}
-
-	return string(b0) == string(b1), nil
-}
-
-type Greater [2]Variable
-
-func (g Greater) Condition(record GroupedRecord) (bool, error) {
-	v0, err := g[0].Variable(SelectedRecord{Source: record.Source, Grouped: record.Grouped})
-	if err != nil {
-		return false, err
-	}
-
-	b0, err := json.Marshal(v0)
-	if err != nil {
-		return false, err
-	}
-
-	v1, err := g[1].Variable(SelectedRecord{Source: record.Source, Grouped: record.Grouped})
-	if err != nil {
-		return false, err
-	}
-
-	b1, err := json.Marshal(v1)
-	if err != nil {
-		return false, err
-	}
-
-	if string(b0) == null || string(b1) == null {
-		return false, nil
-	}
-
-	r0 := gjson.ParseBytes(b0)
-	r1 := gjson.ParseBytes(b1)
-
-	if r0.Type != r1.Type {
-		return false, nil
-	}
-
-	return r1.Less(r0, true), nil
-}
-
-type Less [2]Variable
-
-func (l Less) Condition(record GroupedRecord) (bool, error) {
-	v0, err := l[0].Variable(SelectedRecord{Source: record.Source, Grouped: record.Grouped})
-	if err != nil {
-		return false, err
-	}
-
-	b0, err := json.Marshal(v0)
-	if err != nil {
-		return false, err
-	}
-
-	v1, err := l[1].Variable(SelectedRecord{Source: record.Source, Grouped: record.Grouped})
-	if err != nil {
-		return false, err
-	}
-
-	b1, err := json.Marshal(v1)
-	if err != nil {
-		return false, err
-	}
-
-	if string(b0) == null || string(b1) == null {
-		return false, nil
-	}
-
-	r0 := gjson.ParseBytes(b0)
-	r1 := gjson.ParseBytes(b1)
-
-	if r0.Type != r1.Type {
-		return false, fmt.Errorf("querify: cannot compare types '%s' and '%s'", r0.Type, r1.Type)
-	}
-
-	return r0.Less(r1, true), nil
+	return "CUBE " + set, nil
 }
 
 type Asc struct {
@@ -1368,57 +1493,20 @@ type Asc struct {
 }
 
 func (a Asc) OrderBy(i, j SelectedRecord) (int, error) {
-	vi, err := a.Expression.Variable(i)
-	if err != nil {
-		return 0, err
-	}
-
-	bi, err := json.Marshal(vi)
-	if err != nil {
-		return 0, err
-	}
-
-	vj, err := a.Expression.Variable(j)
-	if err != nil {
-		return 0, err
-	}
+	return order(a.Expression, i, j, false, a.NullsLast)
+}
 
-	bj, err := json.Marshal(vj)
+func (a Asc) SQL() (string, error) {
+	expr, err := render(a.Expression)
 	if err != nil {
-		return 0, err
-	}
-
-	if string(bi) == null {
-		if string(bj) == null {
-			return 0, nil
-		}
-		if a.NullsLast {
-			return 1, nil
-		}
-
-		return -1, nil
+		return "", err
 	}
 
-	if string(bj) == null {
-		if a.NullsLast {
-			return -1, nil
-		}
-
-		return 1, nil
+	if a.NullsLast {
+		return expr + " ASC", nil
 	}
 
-	ri := gjson.ParseBytes(bi)
-	rj := gjson.ParseBytes(bj)
-
-	if ri.Type != rj.Type {
-		return 0, fmt.Errorf("querify: cannot compare types '%s' and '%s'", ri.Type, rj.Type)
-	}
-
-	if ri.Less(rj, true) {
-		return -1, nil
-	}
-
-	return 1, nil
+	return expr + " ASC NULLS FIRST", nil
 }
 
 type Desc struct {
@@ -1427,112 +1515,18 @@ type Desc struct {
 }
 
 func (d Desc) OrderBy(i, j SelectedRecord) (int, error) {
-	vi, err := d.Expression.Variable(i)
-	if err != nil {
-		return 0, err
-	}
-
-	bi, err := json.Marshal(vi)
-	if err != nil {
-		return 0, err
-	}
-
-	vj, err := d.Expression.Variable(j)
-	if err != nil {
-		return 0, err
-	}
-
-	bj, err := json.Marshal(vj)
-	if err != nil {
-		return 0, err
-	}
-
-	if string(bi) == null {
-		if string(bj) == null {
-			return 0, nil
-		}
-		if d.NullsLast {
-			return 1, nil
-		}
-
-		return -1, nil
-	}
-
-	if string(bj) == null {
-		if d.NullsLast {
-			return -1, nil
-		}
-
-		return 1, nil
-	}
-
-	ri := gjson.ParseBytes(bi)
-	rj := gjson.ParseBytes(bj)
-
-	if ri.Type != rj.Type {
-		return 0, fmt.Errorf("querify: cannot compare types '%s' and '%s'", ri.Type, rj.Type)
-	}
-
-	if !ri.Less(rj, true) {
-		return -1, nil
-	}
-
-	return 1, nil
-}
-
-type LeftJoin struct {
-	Right Query
-	On    Condition
+	return order(d.Expression, i, j, true, d.NullsLast)
 }
 
-func (lj LeftJoin) Join(left Query) Table {
-	l := left.Query()
-	if l.Err != nil {
-		return Table{Err: l.Err}
-	}
-
-	r := lj.Right.Query()
-	if r.Err != nil {
-		return Table{Err: r.Err}
-	}
-
-	if len(r.Columns) == 0 {
-		return l
+func (d Desc) SQL() (string, error) {
+	expr, err := render(d.Expression)
+	if err != nil {
+		return "", err
 	}
 
-	columns := append([]string{}, l.Columns...)
-	columns = append(columns, r.Columns...)
-	data := make([][]Value, 0, len(l.Data))
-
-	var err error
-	var ok bool
-
-	for _, dl := range l.Data {
-		found := false
-		for _, dr := range r.Data {
-			ok, err = lj.On.Condition(GroupedRecord{
-				Source: Record{
-					Columns: columns,
-					Values:  append(dl, dr...),
-				},
-			})
-			if err != nil {
-				return Table{Err: err}
-			}
-
-			if ok {
-				found = true
-				data = append(data, append(dl, dr...))
-			}
-		}
-
-		if !found {
-			data = append(data, append(dl, make([]Value, len(r.Columns))...))
-		}
+	if d.NullsLast {
+		return expr + " DESC NULLS LAST", nil
 	}
 
-	return Table{
-		Columns: columns,
-		Data:    data,
-	}
+	return expr + " DESC", nil
 }
@@ -67,3 +67,46 @@ func Test(t *testing.T) {
 		t.Fatal(users)
 	}
 }
+
+func TestIdent(t *testing.T) {
+	record := func(columns ...string) querify.SelectedRecord {
+		values := make([]querify.Value, len(columns))
+
+		for i := range columns {
+			values[i] = i
+		}
+
+		return querify.SelectedRecord{Source: querify.Record{Columns: columns, Values: values}}
+	}
+
+	tests := []struct {
+		ident  querify.Ident
+		record querify.SelectedRecord
+		want   querify.Value
+	}{
+		{"id", record("id", "u.id"), 0},
+		{"id", record("name", "u.id"), 1},
+		{"u.id", record("l.id", "u.id"), 1},
+		{"u.", record("u.id"), 0},
+	}
+
+	for _, test := range tests {
+		got, err := test.ident.Variable(test.record)
+		if err != nil || got != test.want {
+			t.Fatal(test.ident, got, err)
+		}
+	}
+
+	for _, test := range []struct {
+		ident  querify.Ident
+		record querify.SelectedRecord
+	}{
+		{"id", record("l.id", "r.id")},
+		{"x.id", record("u.id")},
+		{"d", record("u.id")},
+	} {
+		if _, err := test.ident.Variable(test.record); err == nil {
+			t.Fatal(test.ident)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package querify
+
+// Coalesce returns the first argument that is not NULL.
+type Coalesce []Variable
+
+func (c Coalesce) Variable(record SelectedRecord) (Value, error) {
+	for _, v := range c {
+		value, err := v.Variable(record)
+		if err != nil || value != nil {
+			return value, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (c Coalesce) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("coalesce", c, table)
+}
+
+func (c Coalesce) SQL() (string, error) {
+	return renderCall("coalesce", c...)
+}
+
+// NullIf returns NULL if both arguments are equal, and the first argument otherwise.
+type NullIf [2]Variable
+
+func (ni NullIf) Variable(record SelectedRecord) (Value, error) {
+	l, err := ni[0].Variable(record)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := ni[1].Variable(record)
+	if err != nil || l == nil || r == nil {
+		return l, err
+	}
+
+	c, err := compare(l, r)
+	if err != nil || c == 0 {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (ni NullIf) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("nullif", ni, table)
+}
+
+func (ni NullIf) SQL() (string, error) {
+	return renderCall("nullif", ni[:]...)
+}
+
+// Greatest returns the largest argument. NULL arguments are ignored.
+type Greatest []Variable
+
+func (g Greatest) Variable(record SelectedRecord) (Value, error) {
+	return extreme(g, record, 1)
+}
+
+func (g Greatest) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("greatest", g, table)
+}
+
+func (g Greatest) SQL() (string, error) {
+	return renderCall("greatest", g...)
+}
+
+// Least returns the smallest argument. NULL arguments are ignored.
+type Least []Variable
+
+func (l Least) Variable(record SelectedRecord) (Value, error) {
+	return extreme(l, record, -1)
+}
+
+func (l Least) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("least", l, table)
+}
+
+func (l Least) SQL() (string, error) {
+	return renderCall("least", l...)
+}
+
+// extreme returns the value, that compares with sign to all other values.
+func extreme(vars []Variable, record SelectedRecord, sign int) (Value, error) {
+	var out Value
+
+	for _, v := range vars {
+		value, err := v.Variable(record)
+		if err != nil {
+			return nil, err
+		}
+
+		if value == nil {
+			continue
+		}
+
+		if out == nil {
+			out = value
+
+			continue
+		}
+
+		c, err := compare(value, out)
+		if err != nil {
+			return nil, err
+		}
+
+		if c*sign > 0 {
+			out = value
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,78 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestScalar(t *testing.T) {
+	record := querify.SelectedRecord{Source: querify.Record{
+		Columns: []string{"int", "float", "null", "text"},
+		Values:  []querify.Value{2, 3.5, nil, "a"},
+	}}
+
+	i, f, n, s := querify.Ident("int"), querify.Ident("float"), querify.Ident("null"), querify.Ident("text")
+
+	tests := []struct {
+		variable querify.Variable
+		want     querify.Value
+	}{
+		{querify.Coalesce{n, i, f}, 2},
+		{querify.Coalesce{n, n}, nil},
+		{querify.NullIf{i, querify.Literal{Value: 2.0}}, nil},
+		{querify.NullIf{i, f}, 2},
+		{querify.NullIf{i, n}, 2},
+		{querify.NullIf{n, i}, nil},
+		{querify.Greatest{i, n, f}, 3.5},
+		{querify.Greatest{n, n}, nil},
+		{querify.Least{f, n, i}, 2},
+		{querify.Least{s, querify.Literal{Value: "b"}}, "a"},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Variable(record)
+		if err != nil {
+			t.Fatal(test.variable, err)
+		}
+
+		if got != test.want {
+			t.Fatal(test.variable, got)
+		}
+	}
+
+	for _, v := range []querify.Variable{querify.Greatest{i, s}, querify.NullIf{s, i}} {
+		if _, err := v.Variable(record); err == nil {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestParseScalar(t *testing.T) {
+	stmt, err := querify.Parse(`SELECT u.name, coalesce(h.name, 'none') AS hobby, nullif(u.id, 2) AS id, `+
+		`greatest(u.id, uh.hobby_id, NULL) AS greatest, least(u.id, uh.hobby_id) AS least `+
+		`FROM users u LEFT JOIN user_hobbies uh ON u.id = uh.user_id AND uh.hobby_id > 1 `+
+		`LEFT JOIN hobbies h ON h.id = uh.hobby_id ORDER BY u.id`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"name": "Max", "hobby": "Basketball", "id": 1.0, "greatest": 2.0, "least": 1.0},
+		{"name": "Tom", "hobby": "Hockey", "id": nil, "greatest": 3.0, "least": 2.0},
+		{"name": "Alex", "hobby": "none", "id": 3.0, "greatest": 3.0, "least": 3.0},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, tables())
+}
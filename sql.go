@@ -0,0 +1,308 @@
+package querify
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func render(value interface{}) (string, error) {
+	s, ok := value.(SQL)
+	if !ok {
+		return "", fmt.Errorf("querify: cannot render '%T' as sql", value)
+	}
+
+	return s.SQL()
+}
+
+func renderList(values []interface{}) (string, error) {
+	out := make([]string, len(values))
+
+	for i, v := range values {
+		s, err := render(v)
+		if err != nil {
+			return "", err
+		}
+
+		out[i] = s
+	}
+
+	return strings.Join(out, ", "), nil
+}
+
+func renderVariables(vars []Variable) (string, error) {
+	values := make([]interface{}, len(vars))
+
+	for i, v := range vars {
+		values[i] = v
+	}
+
+	return renderList(values)
+}
+
+func renderCall(name string, vars ...Variable) (string, error) {
+	args, err := renderVariables(vars)
+	if err != nil {
+		return "", err
+	}
+
+	return name + "(" + args + ")", nil
+}
+
+func renderBinary(left interface{}, op string, right interface{}) (string, error) {
+	l, err := render(left)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := render(right)
+	if err != nil {
+		return "", err
+	}
+
+	return l + " " + op + " " + r, nil
+}
+
+func renderFrom(query Query) (string, error) {
+	switch q := query.(type) {
+	case Table:
+		return q.fromSQL()
+	case source:
+		return q.fromSQL()
+	}
+
+	s, err := render(query)
+	if err != nil {
+		return "", err
+	}
+
+	return "(" + s + ")", nil
+}
+
+func renderValue(value Value) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+
+		return "FALSE", nil
+	case string:
+		return quoteString(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return renderFloat(float64(v), 32), nil
+	case float64:
+		return renderFloat(v, 64), nil
+	case time.Time:
+		return quoteString(v.Format(time.RFC3339Nano)) + "::timestamptz", nil
+	case Interval:
+		return quoteString(v.String()) + "::interval", nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return quoteString(string(b)) + "::jsonb", nil
+}
+
+// renderFloat keeps whole floats apart from integers and casts NaN and infinities.
+func renderFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'::float8"
+	case math.IsInf(f, 1):
+		return "'Infinity'::float8"
+	case math.IsInf(f, -1):
+		return "'-Infinity'::float8"
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if strings.Trim(s, "-0123456789") == "" {
+		return s + ".0"
+	}
+
+	return s
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func quoteIdent(name string) string {
+	plain := name != "" && !reserved[name]
+
+	for i := 0; i < len(name) && plain; i++ {
+		c := name[i]
+		plain = c == '_' || c >= 'a' && c <= 'z' || i > 0 && (isDigit(c) || c == '$')
+	}
+
+	if plain {
+		return name
+	}
+
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+const (
+	stageFrom = iota
+	stageJoin
+	stageWhere
+	stageGroupBy
+	stageHaving
+	stageSelect
+	stageDistinct
+	stageOrderBy
+	stageLimit
+)
+
+func (s Statement) stage() int {
+	switch {
+	case s.Limit != nil || s.Offset != nil:
+		return stageLimit
+	case len(s.OrderBy) > 0:
+		return stageOrderBy
+	case s.Distinct:
+		return stageDistinct
+	case s.Select != nil:
+		return stageSelect
+	case s.Having != nil:
+		return stageHaving
+	case len(s.GroupBy) > 0:
+		return stageGroupBy
+	case s.Where != nil:
+		return stageWhere
+	case len(s.Joins) > 0:
+		return stageJoin
+	}
+
+	return stageFrom
+}
+
+// extend returns the statement traced so far, or a new statement selecting from the query.
+func extend(query Query, statement *Statement, stage int) Statement {
+	if statement == nil || statement.stage() > stage {
+		return Statement{From: query}
+	}
+
+	s := *statement
+	s.Select = append([]Select(nil), s.Select...)
+	s.Joins = append([]Join(nil), s.Joins...)
+	s.GroupBy = append([]GroupBy(nil), s.GroupBy...)
+	s.OrderBy = append([]OrderBy(nil), s.OrderBy...)
+
+	return s
+}
+
+func traceQuery(query Query, stage int) Statement {
+	switch q := query.(type) {
+	case Table:
+		return q.trace(stage)
+	case SelectedTable:
+		return q.trace(stage)
+	case Statement:
+		return extend(q, &q, stage)
+	}
+
+	return Statement{From: query}
+}
+
+func (t Table) trace(stage int) Statement {
+	if t.alias != "" {
+		return Statement{From: t}
+	}
+
+	return extend(t, t.statement, stage)
+}
+
+func (t GroupedTable) trace(stage int) Statement {
+	if t.statement == nil {
+		return t.Source.trace(stage)
+	}
+
+	return extend(t.Source, t.statement, stage)
+}
+
+func (t SelectedTable) trace(stage int) Statement {
+	return extend(t.Query(), t.statement, stage)
+}
+
+func (t Table) fromSQL() (string, error) {
+	switch {
+	case t.statement != nil:
+		s, err := t.statement.SQL()
+		if err != nil {
+			return "", err
+		}
+
+		if t.alias == "" {
+			return "(" + s + ")", nil
+		}
+
+		return "(" + s + ") AS " + quoteIdent(t.alias), nil
+	case t.name != "":
+		if t.alias == "" || t.alias == t.name {
+			return quoteIdent(t.name), nil
+		}
+
+		return quoteIdent(t.name) + " AS " + quoteIdent(t.alias), nil
+	}
+
+	return t.valuesSQL()
+}
+
+func (t Table) valuesSQL() (string, error) {
+	alias := "t"
+	columns := make([]string, len(t.Columns))
+
+	for i, c := range t.Columns {
+		if index := strings.LastIndex(c, "."); index >= 0 {
+			alias = c[:index]
+		}
+
+		columns[i] = quoteIdent(c[strings.LastIndex(c, ".")+1:])
+	}
+
+	if len(t.Data) == 0 || len(columns) == 0 {
+		nulls := make([]string, len(columns))
+
+		for i, c := range columns {
+			nulls[i] = "NULL AS " + c
+		}
+
+		return "(SELECT " + strings.Join(nulls, ", ") + " LIMIT 0) AS " + quoteIdent(alias), nil
+	}
+
+	rows := make([]string, len(t.Data))
+
+	for i, d := range t.Data {
+		values := make([]string, len(columns))
+
+		for j := range columns {
+			var value Value
+			if j < len(d) {
+				value = d[j]
+			}
+
+			s, err := renderValue(value)
+			if err != nil {
+				return "", err
+			}
+
+			values[j] = s
+		}
+
+		rows[i] = "(" + strings.Join(values, ", ") + ")"
+	}
+
+	return "(VALUES " + strings.Join(rows, ", ") + ") AS " + quoteIdent(alias) +
+		" (" + strings.Join(columns, ", ") + ")", nil
+}
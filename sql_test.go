@@ -0,0 +1,139 @@
+package querify_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestSQL(t *testing.T) {
+	table := tables()
+
+	selected := table["users"].Query().As("users").
+		Join(querify.LeftJoin{
+			Right: table["user_hobbies"].Query().As("user_hobbies").As("uh"),
+			On:    querify.Equals{querify.Ident("users.id"), querify.Ident("uh.user_id")},
+		}).
+		Where(querify.And{
+			querify.Or{
+				querify.Less{querify.Ident("users.id"), querify.Literal{Value: 3}},
+				querify.Equals{querify.Ident("users.name"), querify.Literal{Value: "O'Neil"}},
+			},
+			querify.Greater{querify.Ident("users.id"), querify.Literal{Value: -1}},
+		}).
+		GroupBy(querify.Ident("users.name")).
+		Select(
+			querify.As{Name: "Name", Expression: querify.Ident("users.name")},
+			querify.CountAll{},
+		).
+		OrderBy(querify.Desc{Expression: querify.Ident("count")}).
+		Limit(3).
+		Offset(1)
+
+	want := `SELECT users.name AS "Name", count(*) FROM users LEFT JOIN user_hobbies AS uh ON users.id = uh.user_id ` +
+		`WHERE (users.id < 3 OR users.name = 'O''Neil') AND users.id > -1 GROUP BY users.name ORDER BY count DESC ` +
+		`LIMIT 2 OFFSET 1`
+
+	sql, err := selected.SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sql != want {
+		t.Fatal(sql)
+	}
+
+	stmt, err := querify.Parse(sql, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := stmt.SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again != want {
+		t.Fatal(again)
+	}
+}
+
+func TestSQLSubquery(t *testing.T) {
+	stmt, err := querify.Parse(`SELECT * FROM (SELECT name FROM users WHERE id > 1) AS u ORDER BY name`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, err := stmt.Execute().SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sql != `SELECT * FROM (SELECT name FROM users WHERE id > 1) AS u ORDER BY name ASC` {
+		t.Fatal(sql)
+	}
+}
+
+func TestSQLFloat(t *testing.T) {
+	for _, f := range []float64{2, -0.5, 1e21, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		sql, err := querify.Literal{Value: f}.SQL()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stmt, err := querify.Parse(`SELECT `+sql+` AS f, `+sql+` / 2 AS half FROM users LIMIT 1`, tables())
+		if err != nil {
+			t.Fatal(sql, err)
+		}
+
+		table := stmt.Query()
+		if table.Err != nil {
+			t.Fatal(sql, table.Err)
+		}
+
+		if len(table.Data) != 1 || !same(table.Data[0][0], f) || !same(table.Data[0][1], f/2) {
+			t.Fatal(sql, table.Data)
+		}
+	}
+}
+
+func same(value querify.Value, f float64) bool {
+	v, ok := value.(float64)
+
+	return ok && (v == f || math.IsNaN(v) && math.IsNaN(f))
+}
+
+// roundTrip parses the SQL text of the statement and checks, that both statements return the same rows.
+func roundTrip(t *testing.T, stmt querify.Statement, tables map[string]querify.Query) {
+	t.Helper()
+
+	sql, err := stmt.SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := querify.Parse(sql, tables)
+	if err != nil {
+		t.Fatal(sql, err)
+	}
+
+	if rendered, err := again.SQL(); err != nil || rendered != sql {
+		t.Fatal(rendered, err)
+	}
+
+	var want, got []map[string]interface{}
+
+	if err = stmt.Scan(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = again.Scan(&got); err != nil {
+		t.Fatal(sql, err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatal(sql, got, want)
+	}
+}
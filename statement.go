@@ -0,0 +1,268 @@
+package querify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Statement struct {
+	Distinct bool
+	Select   []Select
+	From     Query
+	Joins    []Join
+	Where    Condition
+	GroupBy  []GroupBy
+	Having   Condition
+	OrderBy  []OrderBy
+	Limit    *uint64
+	Offset   *uint64
+}
+
+func (s Statement) Execute() SelectedTable {
+	return s.execute(nil, time.Time{})
+}
+
+// execute runs the statement as subquery of the outer record.
+func (s Statement) execute(outer *GroupedRecord, now time.Time) SelectedTable {
+	if s.From == nil {
+		return SelectedTable{Err: fmt.Errorf("querify: statement has no from clause")}
+	}
+
+	if now.IsZero() {
+		now = clock()
+	}
+
+	joins := make([]Join, len(s.Joins))
+
+	for i, j := range s.Joins {
+		joins[i] = bindJoin(j, outer, now)
+	}
+
+	table := s.From.Query().Join(joins...)
+
+	if s.Where != nil {
+		table = table.Where(bind(s.Where, outer, now))
+	}
+
+	grouped := GroupedTable{Err: table.Err, Source: table}
+
+	if len(s.GroupBy) > 0 {
+		grouped = table.GroupBy(s.GroupBy...)
+	}
+
+	if s.Having != nil {
+		grouped = grouped.Having(bind(s.Having, outer, now))
+	}
+
+	grouped.outer, grouped.now = outer, now
+
+	selected := grouped.Select(s.Select...)
+
+	if s.Distinct {
+		selected = selected.Distinct()
+	}
+
+	if len(s.OrderBy) > 0 {
+		selected = selected.OrderBy(s.OrderBy...)
+	}
+
+	if s.Offset != nil {
+		selected = selected.Offset(*s.Offset)
+	}
+
+	if s.Limit != nil {
+		selected = selected.Limit(*s.Limit)
+	}
+
+	return selected
+}
+
+func bind(condition Condition, outer *GroupedRecord, now time.Time) Condition {
+	if condition == nil {
+		return nil
+	}
+
+	return correlated{condition: condition, outer: outer, now: now}
+}
+
+func bindJoin(j Join, outer *GroupedRecord, now time.Time) Join {
+	switch j := j.(type) {
+	case LeftJoin:
+		j.On = bind(j.On, outer, now)
+
+		return j
+	case InnerJoin:
+		j.On = bind(j.On, outer, now)
+
+		return j
+	case RightJoin:
+		j.On = bind(j.On, outer, now)
+
+		return j
+	case FullJoin:
+		j.On = bind(j.On, outer, now)
+
+		return j
+	}
+
+	return j
+}
+
+func (s Statement) Query() Table {
+	return s.Execute().Query()
+}
+
+func (s Statement) Scan(dest interface{}) error {
+	return s.Execute().Scan(dest)
+}
+
+func (s Statement) SQL() (string, error) {
+	b := strings.Builder{}
+
+	b.WriteString("SELECT ")
+
+	if s.Distinct {
+		b.WriteString("DISTINCT ")
+	}
+
+	if len(s.Select) == 0 {
+		b.WriteString("*")
+	}
+
+	for i, sel := range s.Select {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		str, err := render(sel)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(str)
+	}
+
+	if s.From == nil {
+		return "", fmt.Errorf("querify: statement has no from clause")
+	}
+
+	from, err := renderFrom(s.From)
+	if err != nil {
+		return "", err
+	}
+
+	b.WriteString(" FROM " + from)
+
+	for _, j := range s.Joins {
+		str, err := render(j)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(" " + str)
+	}
+
+	if s.Where != nil {
+		str, err := render(s.Where)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(" WHERE " + str)
+	}
+
+	for i, g := range s.GroupBy {
+		if i == 0 {
+			b.WriteString(" GROUP BY ")
+		} else {
+			b.WriteString(", ")
+		}
+
+		str, err := render(g)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(str)
+	}
+
+	if s.Having != nil {
+		str, err := render(s.Having)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(" HAVING " + str)
+	}
+
+	for i, o := range s.OrderBy {
+		if i == 0 {
+			b.WriteString(" ORDER BY ")
+		} else {
+			b.WriteString(", ")
+		}
+
+		str, err := render(o)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(str)
+	}
+
+	if s.Limit != nil {
+		b.WriteString(" LIMIT " + strconv.FormatUint(*s.Limit, 10))
+	}
+
+	if s.Offset != nil {
+		b.WriteString(" OFFSET " + strconv.FormatUint(*s.Offset, 10))
+	}
+
+	return b.String(), nil
+}
+
+// source is a table or subquery, that is queried when the statement is executed.
+type source struct {
+	query Query
+	name  string
+	alias string
+}
+
+func (s source) Query() Table {
+	table := s.query.Query()
+
+	if s.name != "" {
+		table = table.As(s.name)
+	}
+
+	if s.alias != "" && s.alias != s.name {
+		table = table.As(s.alias)
+	}
+
+	return table
+}
+
+func (s source) SQL() (string, error) {
+	return renderFrom(s)
+}
+
+func (s source) fromSQL() (string, error) {
+	from := quoteIdent(s.name)
+
+	if s.name == "" {
+		sql, err := render(s.query)
+		if err != nil {
+			return "", err
+		}
+
+		from = "(" + sql + ")"
+	}
+
+	if s.alias == "" || s.alias == s.name {
+		return from, nil
+	}
+
+	return from + " AS " + quoteIdent(s.alias), nil
+}
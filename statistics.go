@@ -0,0 +1,449 @@
+package querify
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// VarSamp returns the sample variance of numbers, or NULL for less than two values.
+type VarSamp struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (v VarSamp) Variable(record SelectedRecord) (Value, error) {
+	return variance(record, "var_samp", v.Distinct, v.Expression, v.Filter, v.OrderBy, true, false)
+}
+
+func (v VarSamp) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("var_samp", v, table)
+}
+
+func (v VarSamp) SQL() (string, error) {
+	return renderAggregate("var_samp", v.Distinct, v.OrderBy, v.Filter, v.Expression)
+}
+
+// VarPop returns the population variance of numbers.
+type VarPop struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (v VarPop) Variable(record SelectedRecord) (Value, error) {
+	return variance(record, "var_pop", v.Distinct, v.Expression, v.Filter, v.OrderBy, false, false)
+}
+
+func (v VarPop) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("var_pop", v, table)
+}
+
+func (v VarPop) SQL() (string, error) {
+	return renderAggregate("var_pop", v.Distinct, v.OrderBy, v.Filter, v.Expression)
+}
+
+// StddevSamp returns the sample standard deviation of numbers, or NULL for less than two values.
+type StddevSamp struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (s StddevSamp) Variable(record SelectedRecord) (Value, error) {
+	return variance(record, "stddev_samp", s.Distinct, s.Expression, s.Filter, s.OrderBy, true, true)
+}
+
+func (s StddevSamp) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("stddev_samp", s, table)
+}
+
+func (s StddevSamp) SQL() (string, error) {
+	return renderAggregate("stddev_samp", s.Distinct, s.OrderBy, s.Filter, s.Expression)
+}
+
+// StddevPop returns the population standard deviation of numbers.
+type StddevPop struct {
+	Distinct   bool
+	Expression Select
+	Filter     Condition
+	OrderBy    []OrderBy
+}
+
+func (s StddevPop) Variable(record SelectedRecord) (Value, error) {
+	return variance(record, "stddev_pop", s.Distinct, s.Expression, s.Filter, s.OrderBy, false, true)
+}
+
+func (s StddevPop) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("stddev_pop", s, table)
+}
+
+func (s StddevPop) SQL() (string, error) {
+	return renderAggregate("stddev_pop", s.Distinct, s.OrderBy, s.Filter, s.Expression)
+}
+
+// PercentileCont interpolates the value at Fraction of the sorted numbers.
+type PercentileCont struct {
+	Fraction   Variable
+	Expression Select
+	Desc       bool
+	Filter     Condition
+}
+
+func (p PercentileCont) Variable(record SelectedRecord) (Value, error) {
+	fraction, values, err := percentile(record, "percentile_cont", p.Fraction, p.Expression, p.Desc, p.Filter)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	for _, v := range values {
+		if kindOf(v) != kindNumber {
+			return nil, fmt.Errorf("querify: function percentile_cont expects numbers, got type '%s'", kindOf(v))
+		}
+	}
+
+	position := fraction * float64(len(values)-1)
+	lower := math.Floor(position)
+
+	low, _ := toFloat(values[int(lower)])
+	high, _ := toFloat(values[int(math.Ceil(position))])
+
+	return low + (high-low)*(position-lower), nil
+}
+
+func (p PercentileCont) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("percentile_cont", p, table)
+}
+
+func (p PercentileCont) SQL() (string, error) {
+	return renderOrderedSet("percentile_cont", p.Expression, p.Desc, p.Filter, p.Fraction)
+}
+
+// PercentileDisc returns the first sorted value at Fraction or above.
+type PercentileDisc struct {
+	Fraction   Variable
+	Expression Select
+	Desc       bool
+	Filter     Condition
+}
+
+func (p PercentileDisc) Variable(record SelectedRecord) (Value, error) {
+	fraction, values, err := percentile(record, "percentile_disc", p.Fraction, p.Expression, p.Desc, p.Filter)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	index := int(math.Ceil(fraction*float64(len(values)))) - 1
+	if index < 0 {
+		index = 0
+	}
+
+	return values[index], nil
+}
+
+func (p PercentileDisc) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("percentile_disc", p, table)
+}
+
+func (p PercentileDisc) SQL() (string, error) {
+	return renderOrderedSet("percentile_disc", p.Expression, p.Desc, p.Filter, p.Fraction)
+}
+
+// Mode returns the most frequent value. Ties are decided by the sort order.
+type Mode struct {
+	Expression Select
+	Desc       bool
+	Filter     Condition
+}
+
+func (m Mode) Variable(record SelectedRecord) (Value, error) {
+	values, err := sorted(record, m.Expression, m.Desc, m.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out        Value
+		best, size int
+	)
+
+	for i, v := range values {
+		if i > 0 {
+			c, err := compare(values[i-1], v)
+			if err != nil {
+				return nil, err
+			}
+
+			if c != 0 {
+				size = 0
+			}
+		}
+
+		size++
+
+		if size > best {
+			out, best = v, size
+		}
+	}
+
+	return out, nil
+}
+
+func (m Mode) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("mode", m, table)
+}
+
+func (m Mode) SQL() (string, error) {
+	return renderOrderedSet("mode", m.Expression, m.Desc, m.Filter)
+}
+
+// Corr returns the correlation coefficient of pairs, where both numbers are not NULL.
+type Corr struct {
+	Y, X    Select
+	Filter  Condition
+	OrderBy []OrderBy
+}
+
+func (c Corr) Variable(record SelectedRecord) (Value, error) {
+	m, err := comoments(record, "corr", c.Y, c.X, c.Filter, c.OrderBy)
+	if err != nil || m.n == 0 || m.xx == 0 || m.yy == 0 {
+		return nil, err
+	}
+
+	return m.xy / math.Sqrt(m.xx*m.yy), nil
+}
+
+func (c Corr) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("corr", c, table)
+}
+
+func (c Corr) SQL() (string, error) {
+	return renderAggregate("corr", false, c.OrderBy, c.Filter, c.Y, c.X)
+}
+
+// CovarPop returns the population covariance of pairs, where both numbers are not NULL.
+type CovarPop struct {
+	Y, X    Select
+	Filter  Condition
+	OrderBy []OrderBy
+}
+
+func (c CovarPop) Variable(record SelectedRecord) (Value, error) {
+	m, err := comoments(record, "covar_pop", c.Y, c.X, c.Filter, c.OrderBy)
+	if err != nil || m.n == 0 {
+		return nil, err
+	}
+
+	return m.xy / m.n, nil
+}
+
+func (c CovarPop) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("covar_pop", c, table)
+}
+
+func (c CovarPop) SQL() (string, error) {
+	return renderAggregate("covar_pop", false, c.OrderBy, c.Filter, c.Y, c.X)
+}
+
+// RegrSlope returns the slope of the least-squares line through pairs, where both numbers are not NULL.
+type RegrSlope struct {
+	Y, X    Select
+	Filter  Condition
+	OrderBy []OrderBy
+}
+
+func (r RegrSlope) Variable(record SelectedRecord) (Value, error) {
+	m, err := comoments(record, "regr_slope", r.Y, r.X, r.Filter, r.OrderBy)
+	if err != nil || m.n == 0 || m.xx == 0 {
+		return nil, err
+	}
+
+	return m.xy / m.xx, nil
+}
+
+func (r RegrSlope) Select(table SelectedTable) (string, []Value, error) {
+	return selectAggregate("regr_slope", r, table)
+}
+
+func (r RegrSlope) SQL() (string, error) {
+	return renderAggregate("regr_slope", false, r.OrderBy, r.Filter, r.Y, r.X)
+}
+
+// variance uses Welford's algorithm, which doesn't lose precision for large numbers with a small variance.
+func variance(record SelectedRecord, name string, distinct bool, expression Select, filter Condition, orderBy []OrderBy,
+	sample, root bool,
+) (Value, error) {
+	record, err := within(record, filter, orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := aggregate(record, distinct, expression)
+	if err != nil {
+		return nil, err
+	}
+
+	var n, mean, m2 float64
+
+	for _, v := range values {
+		x, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("querify: function %s expects numbers, got type '%s'", name, kindOf(v))
+		}
+
+		n++
+		delta := x - mean
+		mean += delta / n
+		m2 += delta * (x - mean)
+	}
+
+	if sample {
+		n--
+	}
+
+	if n < 1 {
+		return nil, nil
+	}
+
+	if root {
+		return math.Sqrt(m2 / n), nil
+	}
+
+	return m2 / n, nil
+}
+
+type moments struct {
+	n, xx, yy, xy float64
+}
+
+// comoments computes the sums of squared deviations and of the products of deviations from the means like variance.
+func comoments(record SelectedRecord, name string, y, x Select, filter Condition, orderBy []OrderBy) (moments, error) {
+	record, err := within(record, filter, orderBy)
+	if err != nil {
+		return moments{}, err
+	}
+
+	_, ys, err := y.Select(record.rows())
+	if err != nil {
+		return moments{}, err
+	}
+
+	_, xs, err := x.Select(record.rows())
+	if err != nil {
+		return moments{}, err
+	}
+
+	var (
+		m            moments
+		meanX, meanY float64
+	)
+
+	for i := range ys {
+		if ys[i] == nil || xs[i] == nil {
+			continue
+		}
+
+		yf, yok := toFloat(ys[i])
+		xf, xok := toFloat(xs[i])
+
+		if !yok || !xok {
+			return moments{}, fmt.Errorf("querify: function %s expects numbers, got types '%s' and '%s'",
+				name, kindOf(ys[i]), kindOf(xs[i]))
+		}
+
+		m.n++
+		dx := xf - meanX
+		dy := yf - meanY
+		meanX += dx / m.n
+		meanY += dy / m.n
+		m.xx += dx * (xf - meanX)
+		m.yy += dy * (yf - meanY)
+		m.xy += dx * (yf - meanY)
+	}
+
+	return m, nil
+}
+
+// percentile evaluates the fraction and returns the sorted values of the group.
+func percentile(record SelectedRecord, name string, fraction Variable, expression Select, desc bool, filter Condition,
+) (float64, []Value, error) {
+	value, err := fraction.Variable(record)
+	if err != nil || value == nil {
+		return 0, nil, err
+	}
+
+	f, ok := toFloat(value)
+	if !ok || f < 0 || f > 1 {
+		return 0, nil, fmt.Errorf("querify: function %s expects a fraction between 0 and 1, got '%v'", name, value)
+	}
+
+	values, err := sorted(record, expression, desc, filter)
+
+	return f, values, err
+}
+
+// sorted returns the values of the group without NULLs, sorted like the comparison conditions.
+func sorted(record SelectedRecord, expression Select, desc bool, filter Condition) ([]Value, error) {
+	record, err := within(record, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := aggregate(record, false, expression)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+
+		var c int
+
+		c, err = compare(values[i], values[j])
+		if desc {
+			return c > 0
+		}
+
+		return c < 0
+	})
+
+	return values, err
+}
+
+// renderOrderedSet renders aggregates like 'percentile_cont(0.5) WITHIN GROUP (ORDER BY x)'.
+func renderOrderedSet(name string, expression Select, desc bool, filter Condition,
+	args ...interface{},
+) (string, error) {
+	s, err := renderAggregate(name, false, nil, nil, args...)
+	if err != nil {
+		return "", err
+	}
+
+	o, err := render(expression)
+	if err != nil {
+		return "", err
+	}
+
+	if desc {
+		o += " DESC"
+	}
+
+	s += " WITHIN GROUP (ORDER BY " + o + ")"
+
+	if filter != nil {
+		cond, err := render(filter)
+		if err != nil {
+			return "", err
+		}
+
+		s += " FILTER (WHERE " + cond + ")"
+	}
+
+	return s, nil
+}
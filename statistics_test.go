@@ -0,0 +1,151 @@
+package querify_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestStatistics(t *testing.T) {
+	group := func(rows ...[]querify.Value) querify.SelectedRecord {
+		return querify.SelectedRecord{Grouped: querify.Table{Columns: []string{"x", "y"}, Data: rows}}
+	}
+
+	column := func(values ...querify.Value) querify.SelectedRecord {
+		rows := make([][]querify.Value, len(values))
+
+		for i, v := range values {
+			rows[i] = []querify.Value{v, nil}
+		}
+
+		return group(rows...)
+	}
+
+	x, y := querify.Ident("x"), querify.Ident("y")
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+
+	values := column(2, 4, 4, nil, 4, 5, 5, 7, 9)
+	large := column(1e9+4, 1e9+7, 1e9+13, 1e9+16)
+	pairs := group([]querify.Value{1, 2}, []querify.Value{2, 4}, []querify.Value{3, 6}, []querify.Value{nil, 1},
+		[]querify.Value{4, 8})
+
+	tests := []struct {
+		variable querify.Variable
+		record   querify.SelectedRecord
+		want     querify.Value
+	}{
+		{querify.VarPop{Expression: x}, values, 4.0},
+		{querify.StddevPop{Expression: x}, values, 2.0},
+		{querify.VarSamp{Expression: x}, values, 32.0 / 7},
+		{querify.StddevSamp{Expression: x}, values, math.Sqrt(32.0 / 7)},
+		{querify.VarSamp{Expression: x}, large, 30.0},
+		{querify.VarSamp{Distinct: true, Expression: x}, column(1, 1, 3), 2.0},
+		{querify.VarSamp{Expression: x}, column(1, nil), nil},
+		{querify.VarPop{Expression: x}, column(1), 0.0},
+		{querify.VarPop{Expression: x}, column(), nil},
+		{querify.PercentileCont{Fraction: lit(0.5), Expression: x}, column(4, 1, nil, 3, 2), 2.5},
+		{querify.PercentileCont{Fraction: lit(0.25), Expression: x}, column(4, 1, 3, 2), 1.75},
+		{querify.PercentileCont{Fraction: lit(0.25), Expression: x, Desc: true}, column(4, 1, 3, 2), 3.25},
+		{querify.PercentileCont{Fraction: lit(1), Expression: x}, column(4, 1), 4.0},
+		{querify.PercentileCont{Fraction: lit(0.5), Expression: x}, column(nil), nil},
+		{querify.PercentileDisc{Fraction: lit(0.5), Expression: x}, column(4, 1, 3, 2), 2},
+		{querify.PercentileDisc{Fraction: lit(0), Expression: x}, column("b", "a"), "a"},
+		{
+			querify.PercentileDisc{Fraction: lit(0.5), Expression: x, Filter: querify.Greater{x, lit(1)}},
+			column(4, 1, 3, 2), 3,
+		},
+		{querify.Mode{Expression: x}, values, 4},
+		{querify.Mode{Expression: x}, column(2, 1, 2, 1), 1},
+		{querify.Mode{Expression: x, Desc: true}, column(2, 1, 2, 1), 2},
+		{querify.Mode{Expression: x}, column(nil), nil},
+		{querify.Corr{Y: y, X: x}, pairs, 1.0},
+		{querify.Corr{Y: y, X: x}, group([]querify.Value{1, 2}, []querify.Value{1, 3}), nil},
+		{querify.CovarPop{Y: y, X: x}, pairs, 2.5},
+		{querify.CovarPop{Y: y, X: x}, values, nil},
+		{querify.RegrSlope{Y: y, X: x}, pairs, 2.0},
+		{querify.RegrSlope{Y: x, X: y}, pairs, 0.5},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Variable(test.record)
+		if err != nil {
+			t.Fatal(test.variable, err)
+		}
+
+		if f, ok := got.(float64); ok {
+			if want, isFloat := test.want.(float64); isFloat && math.Abs(f-want) < 1e-9 {
+				continue
+			}
+		}
+
+		if got != test.want {
+			t.Fatalf("%v: %#v", test.variable, got)
+		}
+	}
+
+	for _, test := range []struct {
+		variable querify.Variable
+		record   querify.SelectedRecord
+	}{
+		{querify.VarPop{Expression: x}, column(1, "a")},
+		{querify.PercentileCont{Fraction: lit(1.5), Expression: x}, column(1)},
+		{querify.PercentileCont{Fraction: lit(0.5), Expression: x}, column("a")},
+		{querify.Corr{Y: y, X: x}, group([]querify.Value{1, true})},
+	} {
+		if _, err := test.variable.Variable(test.record); err == nil {
+			t.Fatal(test.variable)
+		}
+	}
+}
+
+func TestParseStatistics(t *testing.T) {
+	data := tables()
+	data["measurements"] = querify.From([]map[string]interface{}{
+		{"sensor": "a", "x": 1, "y": 2},
+		{"sensor": "a", "x": 2, "y": 4},
+		{"sensor": "a", "x": 3, "y": 7},
+		{"sensor": "a", "x": 3, "y": nil},
+		{"sensor": "b", "x": 10, "y": 1},
+	})
+
+	stmt, err := querify.Parse(`SELECT sensor, stddev_pop(x) AS stddev, var_samp(x) AS variance, `+
+		`percentile_cont(0.5) WITHIN GROUP (ORDER BY x) AS median, `+
+		`percentile_disc(0.5) WITHIN GROUP (ORDER BY y DESC) FILTER (WHERE y > 2) AS disc, `+
+		`mode() WITHIN GROUP (ORDER BY x) AS mode, regr_slope(y, x) AS slope `+
+		`FROM measurements GROUP BY sensor ORDER BY sensor`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{
+			"sensor": "a", "stddev": math.Sqrt(0.6875), "variance": 11.0 / 12, "median": 2.5, "disc": 7.0, "mode": 3.0,
+			"slope": 2.5,
+		},
+		{"sensor": "b", "stddev": 0.0, "variance": nil, "median": 10.0, "disc": nil, "mode": 10.0, "slope": nil},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, data)
+
+	for _, query := range []string{
+		`SELECT percentile_cont(0.5) FROM measurements`,
+		`SELECT sum(x) WITHIN GROUP (ORDER BY x) FROM measurements`,
+		`SELECT mode() WITHIN GROUP (ORDER BY x, y) FROM measurements`,
+	} {
+		if _, err = querify.Parse(query, data); err == nil {
+			t.Fatal(query)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package querify
+
+import (
+	"fmt"
+	"time"
+)
+
+type Exists struct {
+	Query Query
+	Where Condition
+}
+
+func (e Exists) Condition(record GroupedRecord) (bool, error) {
+	return exists(e.Query, e.Where, record)
+}
+
+func (e Exists) SQL() (string, error) {
+	sub, err := renderSubquery(e.Query, e.Where)
+	if err != nil {
+		return "", err
+	}
+
+	return "EXISTS " + sub, nil
+}
+
+type NotExists struct {
+	Query Query
+	Where Condition
+}
+
+func (ne NotExists) Condition(record GroupedRecord) (bool, error) {
+	ok, err := exists(ne.Query, ne.Where, record)
+
+	return !ok, err
+}
+
+func (ne NotExists) SQL() (string, error) {
+	sub, err := renderSubquery(ne.Query, ne.Where)
+	if err != nil {
+		return "", err
+	}
+
+	return "NOT EXISTS " + sub, nil
+}
+
+func exists(query Query, where Condition, record GroupedRecord) (bool, error) {
+	table := subquery(query, record)
+	if table.Err != nil {
+		return false, table.Err
+	}
+
+	if where == nil {
+		return len(table.Data) > 0, nil
+	}
+
+	for _, d := range table.Data {
+		ok, err := where.Condition(GroupedRecord{
+			Source: Record{Columns: table.Columns, Values: d},
+			Outer:  &record,
+			now:    record.now,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// subquery returns the rows of a query for the outer record.
+func subquery(query Query, record GroupedRecord) Table {
+	if s, ok := query.(Statement); ok {
+		return s.execute(&record, record.now).Query()
+	}
+
+	return query.Query()
+}
+
+// prepare executes uncorrelated subqueries once instead of once per record.
+func prepare(condition Condition) Condition {
+	return prepareAt(condition, time.Time{})
+}
+
+func prepareAt(condition Condition, now time.Time) Condition {
+	switch c := condition.(type) {
+	case correlated:
+		return correlated{condition: prepareAt(c.condition, c.now), outer: c.outer, now: c.now}
+	case And:
+		prepared := make(And, len(c))
+
+		for i, a := range c {
+			prepared[i] = prepareAt(a, now)
+		}
+
+		return prepared
+	case Or:
+		return Or{prepareAt(c[0], now), prepareAt(c[1], now)}
+	case Not:
+		return Not{prepareAt(c[0], now)}
+	case Exists:
+		return Exists{Query: materialize(c.Query, now), Where: c.Where}
+	case NotExists:
+		return NotExists{Query: materialize(c.Query, now), Where: c.Where}
+	case InQuery:
+		return InQuery{Expression: c.Expression, Query: materialize(c.Query, now)}
+	case NotInQuery:
+		return NotInQuery{Expression: c.Expression, Query: materialize(c.Query, now)}
+	}
+
+	return condition
+}
+
+func materialize(query Query, now time.Time) Query {
+	var table Table
+
+	if s, ok := query.(Statement); ok {
+		table = s.execute(nil, now).Query()
+	} else {
+		table = query.Query()
+	}
+
+	if table.Err != nil {
+		return query
+	}
+
+	return table
+}
+
+// correlated evaluates a condition of a statement with the record of the outer query and the time of the execution.
+type correlated struct {
+	condition Condition
+	outer     *GroupedRecord
+	now       time.Time
+}
+
+func (c correlated) Condition(record GroupedRecord) (bool, error) {
+	record.Outer, record.now = c.outer, c.now
+
+	return c.condition.Condition(record)
+}
+
+func (c correlated) SQL() (string, error) {
+	return render(c.condition)
+}
+
+func renderSubquery(query Query, where Condition) (string, error) {
+	s := traceQuery(query, stageLimit)
+
+	if where != nil {
+		s = traceQuery(query, stageWhere)
+	}
+
+	switch {
+	case where == nil:
+	case s.Where == nil:
+		s.Where = where
+	default:
+		s.Where = And{s.Where, where}
+	}
+
+	sql, err := s.SQL()
+	if err != nil {
+		return "", err
+	}
+
+	return "(" + sql + ")", nil
+}
+
+type In struct {
+	Expression Variable
+	List       []Variable
+}
+
+func (in In) Condition(record GroupedRecord) (bool, error) {
+	return holds(in, record)
+}
+
+func (in In) Predicate(record GroupedRecord) (Truth, error) {
+	found, unknown, err := memberOfList(in.Expression, in.List, record)
+
+	return membership(found, unknown), err
+}
+
+func (in In) SQL() (string, error) {
+	return renderIn(in.Expression, "IN", in.List)
+}
+
+type NotIn struct {
+	Expression Variable
+	List       []Variable
+}
+
+func (ni NotIn) Condition(record GroupedRecord) (bool, error) {
+	return holds(ni, record)
+}
+
+func (ni NotIn) Predicate(record GroupedRecord) (Truth, error) {
+	found, unknown, err := memberOfList(ni.Expression, ni.List, record)
+
+	return membership(found, unknown).Not(), err
+}
+
+func (ni NotIn) SQL() (string, error) {
+	return renderIn(ni.Expression, "NOT IN", ni.List)
+}
+
+type InQuery struct {
+	Expression Variable
+	Query      Query
+}
+
+func (iq InQuery) Condition(record GroupedRecord) (bool, error) {
+	return holds(iq, record)
+}
+
+func (iq InQuery) Predicate(record GroupedRecord) (Truth, error) {
+	found, unknown, err := memberOfQuery(iq.Expression, iq.Query, record)
+
+	return membership(found, unknown), err
+}
+
+func (iq InQuery) SQL() (string, error) {
+	return renderInQuery(iq.Expression, "IN", iq.Query)
+}
+
+type NotInQuery struct {
+	Expression Variable
+	Query      Query
+}
+
+func (niq NotInQuery) Condition(record GroupedRecord) (bool, error) {
+	return holds(niq, record)
+}
+
+func (niq NotInQuery) Predicate(record GroupedRecord) (Truth, error) {
+	found, unknown, err := memberOfQuery(niq.Expression, niq.Query, record)
+
+	return membership(found, unknown).Not(), err
+}
+
+func (niq NotInQuery) SQL() (string, error) {
+	return renderInQuery(niq.Expression, "NOT IN", niq.Query)
+}
+
+func membership(found, unknown bool) Truth {
+	switch {
+	case found:
+		return True
+	case unknown:
+		return Unknown
+	}
+
+	return False
+}
+
+func memberOfList(expression Variable, list []Variable, record GroupedRecord) (bool, bool, error) {
+	values := make([]Value, len(list))
+
+	for i, v := range list {
+		value, err := v.Variable(record.selected())
+		if err != nil {
+			return false, false, err
+		}
+
+		values[i] = value
+	}
+
+	return member(expression, values, record)
+}
+
+func memberOfQuery(expression Variable, query Query, record GroupedRecord) (bool, bool, error) {
+	table := subquery(query, record)
+	if table.Err != nil {
+		return false, false, table.Err
+	}
+
+	if len(table.Columns) != 1 {
+		return false, false, fmt.Errorf("querify: subquery has too many columns")
+	}
+
+	values := make([]Value, len(table.Data))
+
+	for i, d := range table.Data {
+		if len(d) > 0 {
+			values[i] = d[0]
+		}
+	}
+
+	return member(expression, values, record)
+}
+
+// member reports whether the value is one of the values, or unknown because of NULL values.
+func member(expression Variable, values []Value, record GroupedRecord) (found, unknown bool, err error) {
+	value, err := expression.Variable(record.selected())
+	if err != nil {
+		return false, false, err
+	}
+
+	if len(values) == 0 {
+		return false, false, nil
+	}
+
+	if value == nil {
+		return false, true, nil
+	}
+
+	for _, v := range values {
+		if v == nil {
+			unknown = true
+
+			continue
+		}
+
+		c, err := compare(value, v)
+		if err != nil {
+			return false, false, err
+		}
+
+		if c == 0 {
+			return true, false, nil
+		}
+	}
+
+	return false, unknown, nil
+}
+
+func renderIn(expression Variable, op string, list []Variable) (string, error) {
+	expr, err := render(expression)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := renderVariables(list)
+	if err != nil {
+		return "", err
+	}
+
+	return expr + " " + op + " (" + values + ")", nil
+}
+
+func renderInQuery(expression Variable, op string, query Query) (string, error) {
+	expr, err := render(expression)
+	if err != nil {
+		return "", err
+	}
+
+	sub, err := traceQuery(query, stageLimit).SQL()
+	if err != nil {
+		return "", err
+	}
+
+	return expr + " " + op + " (" + sub + ")", nil
+}
@@ -0,0 +1,215 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestExists(t *testing.T) {
+	users := querify.From([]map[string]interface{}{
+		{"id": 1, "name": "Max"},
+		{"id": 2, "name": "Tom"},
+		{"id": 3, "name": "Alex"},
+	}).As("users")
+
+	orders := querify.From([]map[string]interface{}{
+		{"id": 1, "user_id": 1},
+		{"id": 2, "user_id": 3},
+		{"id": 3, "user_id": 1},
+	}).As("orders")
+
+	correlated := querify.Equals{querify.Ident("orders.user_id"), querify.Ident("users.id")}
+
+	var with, without []string
+
+	err := users.Where(querify.Exists{Query: orders, Where: correlated}).ScanColumn("name", &with)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = users.Where(querify.NotExists{Query: orders, Where: correlated}).ScanColumn("name", &without)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(with, []string{"Max", "Alex"}) || !reflect.DeepEqual(without, []string{"Tom"}) {
+		t.Fatal(with, without)
+	}
+}
+
+func TestParseExists(t *testing.T) {
+	table := tables()
+	table["user_hobbies"] = querify.From([]map[string]interface{}{
+		{"user_id": 1, "hobby_id": 1},
+		{"user_id": 3, "hobby_id": 2},
+	})
+
+	stmt, err := querify.Parse(`
+		SELECT name FROM users u
+		WHERE NOT EXISTS (SELECT * FROM user_hobbies uh WHERE uh.user_id = u.id)`, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"Tom"}) {
+		t.Fatal(names)
+	}
+
+	roundTrip(t, stmt, table)
+
+	for sql, want := range map[string][]string{
+		`SELECT name FROM users u WHERE EXISTS (SELECT 1 FROM user_hobbies uh WHERE uh.user_id = u.id)`:     {"Max", "Alex"},
+		`SELECT name FROM users u WHERE NOT EXISTS (SELECT 1 FROM user_hobbies uh WHERE uh.user_id = u.id)`: {"Tom"},
+	} {
+		stmt, err = querify.Parse(sql, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = nil
+
+		if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(names, want) {
+			t.Fatal(sql, names)
+		}
+
+		roundTrip(t, stmt, table)
+	}
+}
+
+func TestIn(t *testing.T) {
+	values := querify.From([]map[string]interface{}{
+		{"id": 1, "x": 1},
+		{"id": 2, "x": 2},
+		{"id": 3, "x": nil},
+	})
+
+	list := []querify.Variable{querify.Literal{Value: 1}, querify.Literal{Value: 3}}
+	withNull := append(list, querify.Literal{})
+	sub := querify.From([]map[string]interface{}{{"v": 1}, {"v": nil}})
+
+	tests := []struct {
+		condition querify.Condition
+		want      []int
+	}{
+		{querify.In{Expression: querify.Ident("x"), List: list}, []int{1}},
+		{querify.NotIn{Expression: querify.Ident("x"), List: list}, []int{2}},
+		{querify.In{Expression: querify.Ident("x"), List: withNull}, []int{1}},
+		{querify.NotIn{Expression: querify.Ident("x"), List: withNull}, []int{}},
+		{querify.InQuery{Expression: querify.Ident("x"), Query: sub}, []int{1}},
+		{querify.NotInQuery{Expression: querify.Ident("x"), Query: sub}, []int{}},
+	}
+
+	for _, test := range tests {
+		var ids []int
+
+		err := values.Where(test.condition).ScanColumn("id", &ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Fatal(test.condition, ids)
+		}
+	}
+}
+
+func TestParseIn(t *testing.T) {
+	stmt, err := querify.Parse(`
+		SELECT name FROM users
+		WHERE id IN (SELECT user_id FROM user_hobbies WHERE hobby_id = 1) AND name NOT IN ('Alex', 'Tom')`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"Max"}) {
+		t.Fatal(names)
+	}
+
+	roundTrip(t, stmt, tables())
+}
+
+type countingQuery struct {
+	table querify.Table
+	count int
+}
+
+func (c *countingQuery) Query() querify.Table {
+	c.count++
+
+	return c.table
+}
+
+func TestParseCorrelated(t *testing.T) {
+	data := tables()
+	hobbies := &countingQuery{table: data["user_hobbies"].Query()}
+	data["user_hobbies"] = hobbies
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`SELECT name FROM users WHERE id IN (SELECT user_id FROM user_hobbies WHERE hobby_id = 1)`, []string{"Max", "Alex"}},
+		{`SELECT name FROM users u WHERE 1 IN (SELECT hobby_id FROM user_hobbies uh WHERE uh.user_id = u.id)`,
+			[]string{"Max", "Alex"}},
+		{`SELECT name FROM users u WHERE EXISTS (SELECT uh.user_id FROM user_hobbies uh WHERE uh.user_id = u.id ` +
+			`GROUP BY uh.user_id HAVING count(*) > 1)`, []string{"Max"}},
+		{`SELECT name FROM users u WHERE EXISTS (SELECT * FROM user_hobbies uh WHERE uh.user_id = u.id LIMIT 1 OFFSET 1)`,
+			[]string{"Max"}},
+		{`SELECT name FROM users u WHERE u.id IN (SELECT user_id FROM user_hobbies GROUP BY user_id ` +
+			`HAVING count(*) < u.id)`, []string{"Tom", "Alex"}},
+		{`SELECT name FROM users u WHERE 2 IN (SELECT u.id FROM user_hobbies)`, []string{"Tom"}},
+	}
+
+	for _, test := range tests {
+		stmt, err := querify.Parse(test.query, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+
+		if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+			t.Fatal(test.query, err)
+		}
+
+		if !reflect.DeepEqual(names, test.want) {
+			t.Fatal(test.query, names)
+		}
+	}
+
+	stmt, err := querify.Parse(`SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM user_hobbies)`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hobbies.count = 0
+	hobbies.table = querify.From([]map[string]interface{}{{"user_id": 1, "hobby_id": 1}})
+
+	var names []string
+
+	if err = stmt.Execute().ScanColumn("name", &names); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"Tom", "Alex"}) || hobbies.count != 1 {
+		t.Fatal(names, hobbies.count)
+	}
+}
@@ -0,0 +1,743 @@
+package querify
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Lower [1]Variable
+
+func (l Lower) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		return strings.ToLower(args[0]), nil
+	}, l[0])
+}
+
+func (l Lower) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("lower", l, table)
+}
+
+func (l Lower) SQL() (string, error) {
+	return renderCall("lower", l[0])
+}
+
+type Upper [1]Variable
+
+func (u Upper) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		return strings.ToUpper(args[0]), nil
+	}, u[0])
+}
+
+func (u Upper) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("upper", u, table)
+}
+
+func (u Upper) SQL() (string, error) {
+	return renderCall("upper", u[0])
+}
+
+// Trim removes the longest string of Characters, or spaces without Characters, from both ends.
+type Trim struct {
+	Expression Variable
+	Characters Variable
+}
+
+func (t Trim) Variable(record SelectedRecord) (Value, error) {
+	return trim(record, t.Expression, t.Characters, strings.Trim)
+}
+
+func (t Trim) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("btrim", t, table)
+}
+
+func (t Trim) SQL() (string, error) {
+	return renderCall("btrim", optional(t.Expression, t.Characters)...)
+}
+
+type LTrim struct {
+	Expression Variable
+	Characters Variable
+}
+
+func (lt LTrim) Variable(record SelectedRecord) (Value, error) {
+	return trim(record, lt.Expression, lt.Characters, strings.TrimLeft)
+}
+
+func (lt LTrim) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("ltrim", lt, table)
+}
+
+func (lt LTrim) SQL() (string, error) {
+	return renderCall("ltrim", optional(lt.Expression, lt.Characters)...)
+}
+
+type RTrim struct {
+	Expression Variable
+	Characters Variable
+}
+
+func (rt RTrim) Variable(record SelectedRecord) (Value, error) {
+	return trim(record, rt.Expression, rt.Characters, strings.TrimRight)
+}
+
+func (rt RTrim) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("rtrim", rt, table)
+}
+
+func (rt RTrim) SQL() (string, error) {
+	return renderCall("rtrim", optional(rt.Expression, rt.Characters)...)
+}
+
+// Length returns the number of characters.
+type Length [1]Variable
+
+func (l Length) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		return int64(len([]rune(args[0]))), nil
+	}, l[0])
+}
+
+func (l Length) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("length", l, table)
+}
+
+func (l Length) SQL() (string, error) {
+	return renderCall("length", l[0])
+}
+
+// Substring returns For characters starting at the 1-based position From. Without For it returns the rest.
+type Substring struct {
+	Expression Variable
+	From       Variable
+	For        Variable
+}
+
+func (s Substring) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		runes := []rune(args[0])
+
+		start, err := integer(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		end := len(runes) + 1
+
+		if len(args) > 2 {
+			count, err := integer(args[2])
+			if err != nil {
+				return nil, err
+			}
+
+			if count < 0 {
+				return nil, fmt.Errorf("querify: negative substring length not allowed")
+			}
+
+			if start+count < end {
+				end = start + count
+			}
+		}
+
+		if start < 1 {
+			start = 1
+		}
+
+		if start >= end {
+			return "", nil
+		}
+
+		return string(runes[start-1 : end-1]), nil
+	}, s.args()...)
+}
+
+func (s Substring) args() []Variable {
+	if s.For == nil {
+		return []Variable{s.Expression, integerArg{s.From}}
+	}
+
+	return []Variable{s.Expression, integerArg{s.From}, integerArg{s.For}}
+}
+
+func (s Substring) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("substring", s, table)
+}
+
+func (s Substring) SQL() (string, error) {
+	return renderCall("substring", optional(s.Expression, s.From, s.For)...)
+}
+
+// Replace replaces all occurrences of the second argument in the first with the third.
+type Replace [3]Variable
+
+func (r Replace) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		if args[1] == "" {
+			return args[0], nil
+		}
+
+		return strings.ReplaceAll(args[0], args[1], args[2]), nil
+	}, r[:]...)
+}
+
+func (r Replace) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("replace", r, table)
+}
+
+func (r Replace) SQL() (string, error) {
+	return renderCall("replace", r[:]...)
+}
+
+// SplitPart returns the field at the 1-based position. Negative positions count from the end.
+type SplitPart [3]Variable
+
+func (sp SplitPart) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		n, err := integer(args[2])
+		if err != nil {
+			return nil, err
+		}
+
+		if n == 0 {
+			return nil, fmt.Errorf("querify: field position must not be zero")
+		}
+
+		fields := []string{args[0]}
+		if args[1] != "" {
+			fields = strings.Split(args[0], args[1])
+		}
+
+		if n < 0 {
+			n += len(fields) + 1
+		}
+
+		if n < 1 || n > len(fields) {
+			return "", nil
+		}
+
+		return fields[n-1], nil
+	}, sp[0], sp[1], integerArg{sp[2]})
+}
+
+func (sp SplitPart) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("split_part", sp, table)
+}
+
+func (sp SplitPart) SQL() (string, error) {
+	return renderCall("split_part", sp[:]...)
+}
+
+// Position returns the 1-based position of the first argument in the second, or 0 if it is not found.
+type Position [2]Variable
+
+func (p Position) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		index := strings.Index(args[1], args[0])
+		if index < 0 {
+			return int64(0), nil
+		}
+
+		return int64(len([]rune(args[1][:index])) + 1), nil
+	}, p[:]...)
+}
+
+func (p Position) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("position", p, table)
+}
+
+func (p Position) SQL() (string, error) {
+	s, err := renderBinary(p[0], "IN", p[1])
+	if err != nil {
+		return "", err
+	}
+
+	return "position(" + s + ")", nil
+}
+
+// Left returns the first n characters. A negative n returns all but the last |n| characters.
+type Left [2]Variable
+
+func (l Left) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		runes := []rune(args[0])
+
+		n, err := integer(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return string(runes[:clamp(n, len(runes))]), nil
+	}, l[0], integerArg{l[1]})
+}
+
+func (l Left) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("left", l, table)
+}
+
+func (l Left) SQL() (string, error) {
+	return renderCall("left", l[:]...)
+}
+
+// Right returns the last n characters. A negative n returns all but the first |n| characters.
+type Right [2]Variable
+
+func (r Right) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		runes := []rune(args[0])
+
+		n, err := integer(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return string(runes[len(runes)-clamp(n, len(runes)):]), nil
+	}, r[0], integerArg{r[1]})
+}
+
+func (r Right) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("right", r, table)
+}
+
+func (r Right) SQL() (string, error) {
+	return renderCall("right", r[:]...)
+}
+
+// LPad fills the string up to Length on the left. Longer strings are truncated.
+type LPad struct {
+	Expression Variable
+	Length     Variable
+	Fill       Variable
+}
+
+func (lp LPad) Variable(record SelectedRecord) (Value, error) {
+	return pad(record, lp.Expression, lp.Length, lp.Fill, true)
+}
+
+func (lp LPad) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("lpad", lp, table)
+}
+
+func (lp LPad) SQL() (string, error) {
+	return renderCall("lpad", optional(lp.Expression, lp.Length, lp.Fill)...)
+}
+
+type RPad struct {
+	Expression Variable
+	Length     Variable
+	Fill       Variable
+}
+
+func (rp RPad) Variable(record SelectedRecord) (Value, error) {
+	return pad(record, rp.Expression, rp.Length, rp.Fill, false)
+}
+
+func (rp RPad) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("rpad", rp, table)
+}
+
+func (rp RPad) SQL() (string, error) {
+	return renderCall("rpad", optional(rp.Expression, rp.Length, rp.Fill)...)
+}
+
+// RegexpReplace replaces the first match, or all matches with the flag 'g'.
+type RegexpReplace struct {
+	Expression  Variable
+	Pattern     Variable
+	Replacement Variable
+	Flags       Variable
+}
+
+func (rr RegexpReplace) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		re, global, err := compileFlags(args[1], args[3:], cacheable(rr.Pattern, rr.Flags))
+		if err != nil {
+			return nil, err
+		}
+
+		template := replacement(args[2])
+
+		if global {
+			return re.ReplaceAllString(args[0], template), nil
+		}
+
+		loc := re.FindStringSubmatchIndex(args[0])
+		if loc == nil {
+			return args[0], nil
+		}
+
+		return args[0][:loc[0]] + string(re.ExpandString(nil, template, args[0], loc)) + args[0][loc[1]:], nil
+	}, optional(rr.Expression, rr.Pattern, rr.Replacement, rr.Flags)...)
+}
+
+func (rr RegexpReplace) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("regexp_replace", rr, table)
+}
+
+func (rr RegexpReplace) SQL() (string, error) {
+	return renderCall("regexp_replace", optional(rr.Expression, rr.Pattern, rr.Replacement, rr.Flags)...)
+}
+
+// RegexpMatches returns the capture groups of the first match, or of all matches with the flag 'g'.
+type RegexpMatches struct {
+	Expression Variable
+	Pattern    Variable
+	Flags      Variable
+}
+
+func (rm RegexpMatches) Variable(record SelectedRecord) (Value, error) {
+	return strict(record, func(args []string) (Value, error) {
+		re, global, err := compileFlags(args[1], args[2:], cacheable(rm.Pattern, rm.Flags))
+		if err != nil {
+			return nil, err
+		}
+
+		n := 1
+		if global {
+			n = -1
+		}
+
+		found := re.FindAllStringSubmatchIndex(args[0], n)
+		if len(found) == 0 {
+			return nil, nil
+		}
+
+		out := make([]interface{}, len(found))
+
+		for i, loc := range found {
+			groups := loc
+			if len(loc) > 2 {
+				groups = loc[2:]
+			}
+
+			match := make([]interface{}, len(groups)/2)
+
+			for j := range match {
+				if groups[2*j] >= 0 {
+					match[j] = args[0][groups[2*j]:groups[2*j+1]]
+				}
+			}
+
+			out[i] = match
+		}
+
+		if !global {
+			return out[0], nil
+		}
+
+		return out, nil
+	}, optional(rm.Expression, rm.Pattern, rm.Flags)...)
+}
+
+func (rm RegexpMatches) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("regexp_matches", rm, table)
+}
+
+func (rm RegexpMatches) SQL() (string, error) {
+	return renderCall("regexp_matches", optional(rm.Expression, rm.Pattern, rm.Flags)...)
+}
+
+// Format supports the specifiers %s, %I, %L and %% with optional positions like %1$s.
+type Format []Variable
+
+func (f Format) Variable(record SelectedRecord) (Value, error) {
+	if len(f) == 0 {
+		return nil, fmt.Errorf("querify: format expects a format string")
+	}
+
+	values := make([]Value, len(f))
+
+	for i, v := range f {
+		value, err := v.Variable(record)
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = value
+	}
+
+	if values[0] == nil {
+		return nil, nil
+	}
+
+	format, err := text(values[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return formatText(format, values[1:])
+}
+
+func (f Format) Select(table SelectedTable) (string, []Value, error) {
+	return selectVariable("format", f, table)
+}
+
+func (f Format) SQL() (string, error) {
+	return renderCall("format", f...)
+}
+
+// strict returns NULL, if one of the variables is NULL. Arguments must be strings.
+func strict(record SelectedRecord, fn func(args []string) (Value, error), vars ...Variable) (Value, error) {
+	args := make([]string, len(vars))
+
+	for i, v := range vars {
+		value, err := v.Variable(record)
+		if err != nil || value == nil {
+			return nil, err
+		}
+
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("querify: expected text, got type '%s'", kindOf(value))
+		}
+
+		args[i] = s
+	}
+
+	return fn(args)
+}
+
+// integerArg accepts integers and whole numbers as text arguments.
+type integerArg [1]Variable
+
+func (i integerArg) Variable(record SelectedRecord) (Value, error) {
+	value, err := i[0].Variable(record)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	if n, ok := toInt(value); ok {
+		return strconv.FormatInt(n, 10), nil
+	}
+
+	if f, ok := toFloat(value); ok && f == math.Trunc(f) && math.Abs(f) < math.MaxInt32 {
+		return strconv.FormatInt(int64(f), 10), nil
+	}
+
+	return nil, fmt.Errorf("querify: expected an integer, got type '%s'", kindOf(value))
+}
+
+// optional returns the variables up to the first nil variable.
+func optional(vars ...Variable) []Variable {
+	for i, v := range vars {
+		if v == nil {
+			return vars[:i]
+		}
+	}
+
+	return vars
+}
+
+func text(value Value) (string, error) {
+	s, err := castText(value)
+	if err != nil {
+		return "", err
+	}
+
+	return s.(string), nil
+}
+
+func integer(s string) (int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("querify: expected an integer, got '%s'", s)
+	}
+
+	return i, nil
+}
+
+// clamp returns n limited to length, or length+n for negative n.
+func clamp(n, length int) int {
+	if n < 0 {
+		n += length
+	}
+
+	switch {
+	case n < 0:
+		return 0
+	case n > length:
+		return length
+	}
+
+	return n
+}
+
+func trim(record SelectedRecord, expression, characters Variable, fn func(s, cutset string) string) (Value, error) {
+	if characters == nil {
+		characters = Literal{Value: " "}
+	}
+
+	return strict(record, func(args []string) (Value, error) {
+		return fn(args[0], args[1]), nil
+	}, expression, characters)
+}
+
+func pad(record SelectedRecord, expression, length, fill Variable, left bool) (Value, error) {
+	if fill == nil {
+		fill = Literal{Value: " "}
+	}
+
+	return strict(record, func(args []string) (Value, error) {
+		runes, fill := []rune(args[0]), []rune(args[2])
+
+		n, err := integer(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if n <= len(runes) || len(fill) == 0 {
+			return string(runes[:clamp(n, len(runes))]), nil
+		}
+
+		padding := make([]rune, n-len(runes))
+		for i := range padding {
+			padding[i] = fill[i%len(fill)]
+		}
+
+		if left {
+			return string(padding) + string(runes), nil
+		}
+
+		return string(runes) + string(padding), nil
+	}, expression, integerArg{length}, fill)
+}
+
+// compileFlags compiles a pattern with the Postgres flags 'g', 'i' and 'c'.
+func compileFlags(pattern string, flags []string, cache bool) (*regexp.Regexp, bool, error) {
+	var global, insensitive bool
+
+	for _, f := range flags {
+		for _, c := range f {
+			switch c {
+			case 'g':
+				global = true
+			case 'i':
+				insensitive = true
+			case 'c':
+				insensitive = false
+			default:
+				return nil, false, fmt.Errorf("querify: invalid regular expression flag '%c'", c)
+			}
+		}
+	}
+
+	if insensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := compile(pattern, cache)
+
+	return re, global, err
+}
+
+// replacement translates a Postgres replacement string into a template for regexp.Expand.
+func replacement(s string) string {
+	b := strings.Builder{}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && isDigit(s[i+1]):
+			b.WriteString("${" + string(s[i+1]) + "}")
+			i++
+		case c == '\\' && i+1 < len(s) && s[i+1] == '&':
+			b.WriteString("${0}")
+			i++
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\\':
+			b.WriteByte('\\')
+			i++
+		case c == '$':
+			b.WriteString("$$")
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func formatText(format string, args []Value) (string, error) {
+	b := strings.Builder{}
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+
+			continue
+		}
+
+		i++
+
+		if i < len(format) && format[i] == '%' {
+			b.WriteByte('%')
+
+			continue
+		}
+
+		j := i
+		for j < len(format) && isDigit(format[j]) {
+			j++
+		}
+
+		if j > i && j < len(format) && format[j] == '$' {
+			n, _ := strconv.Atoi(format[i:j])
+			if n < 1 {
+				return "", fmt.Errorf("querify: format argument position must be greater than zero")
+			}
+
+			next = n - 1
+			i = j + 1
+		}
+
+		if i >= len(format) {
+			return "", fmt.Errorf("querify: unterminated format specifier")
+		}
+
+		if next >= len(args) {
+			return "", fmt.Errorf("querify: too few arguments for format")
+		}
+
+		arg := args[next]
+		next++
+
+		var s string
+
+		if arg != nil {
+			var err error
+
+			s, err = text(arg)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		switch format[i] {
+		case 's':
+			b.WriteString(s)
+		case 'I':
+			if arg == nil {
+				return "", fmt.Errorf("querify: null values cannot be formatted as an identifier")
+			}
+
+			b.WriteString(quoteIdent(s))
+		case 'L':
+			if arg == nil {
+				b.WriteString("NULL")
+			} else {
+				b.WriteString(quoteString(s))
+			}
+		default:
+			return "", fmt.Errorf("querify: unrecognized format specifier '%c'", format[i])
+		}
+	}
+
+	return b.String(), nil
+}
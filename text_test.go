@@ -0,0 +1,135 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestText(t *testing.T) {
+	record := querify.SelectedRecord{Source: querify.Record{
+		Columns: []string{"text", "null", "number"},
+		Values:  []querify.Value{"  Hällo World  ", nil, 3.0},
+	}}
+
+	text, null, number := querify.Ident("text"), querify.Ident("null"), querify.Ident("number")
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+
+	tests := []struct {
+		variable querify.Variable
+		want     querify.Value
+	}{
+		{querify.Lower{text}, "  hällo world  "},
+		{querify.Upper{text}, "  HÄLLO WORLD  "},
+		{querify.Upper{null}, nil},
+		{querify.Trim{Expression: text}, "Hällo World"},
+		{querify.LTrim{Expression: text}, "Hällo World  "},
+		{querify.RTrim{Expression: lit("xxaxx"), Characters: lit("x")}, "xxa"},
+		{querify.Trim{Expression: text, Characters: null}, nil},
+		{querify.Length{text}, int64(15)},
+		{querify.Substring{Expression: text, From: lit(3), For: lit(5)}, "Hällo"},
+		{querify.Substring{Expression: text, From: lit(9)}, "World  "},
+		{querify.Substring{Expression: lit("abc"), From: lit(0), For: lit(2)}, "a"},
+		{querify.Substring{Expression: lit("abc"), From: null}, nil},
+		{querify.Replace{text, lit("l"), lit("L")}, "  HäLLo WorLd  "},
+		{querify.SplitPart{lit("a,b,c"), lit(","), lit(2)}, "b"},
+		{querify.SplitPart{lit("a,b,c"), lit(","), lit(-1)}, "c"},
+		{querify.SplitPart{lit("a,b,c"), lit(","), lit(4)}, ""},
+		{querify.Position{lit("World"), text}, int64(9)},
+		{querify.Position{lit("x"), text}, int64(0)},
+		{querify.Left{lit("abcde"), lit(2)}, "ab"},
+		{querify.Left{lit("abcde"), lit(-2)}, "abc"},
+		{querify.Right{lit("abcde"), lit(2)}, "de"},
+		{querify.Right{lit("abcde"), lit(-2)}, "cde"},
+		{querify.LPad{Expression: querify.Cast{Expression: number, Type: "text"}, Length: number, Fill: lit("0")}, "003"},
+		{querify.RPad{Expression: lit("ab"), Length: lit(5), Fill: lit("xy")}, "abxyx"},
+		{querify.LPad{Expression: lit("hello"), Length: lit(2)}, "he"},
+		{
+			querify.RegexpReplace{Expression: lit("foobarbaz"), Pattern: lit("b(..)"), Replacement: lit(`X\1Y`)},
+			"fooXarYbaz",
+		},
+		{
+			querify.RegexpReplace{
+				Expression: lit("foobarbaz"), Pattern: lit("B(..)"), Replacement: lit(`<\&>`), Flags: lit("gi"),
+			},
+			"foo<bar><baz>",
+		},
+		{querify.RegexpReplace{Expression: lit("a"), Pattern: lit("b"), Replacement: lit("c")}, "a"},
+		{querify.RegexpMatches{Expression: lit("abc"), Pattern: lit("x")}, nil},
+		{querify.Format{lit("%s, %I and %L%%"), lit("Max"), lit("User Name"), lit("it's")}, `Max, "User Name" and 'it''s'%`},
+		{querify.Format{lit("%2$s %1$s %s"), lit("a"), lit("b")}, "b a b"},
+		{querify.Format{lit("%s|%L"), null, null}, "|NULL"},
+		{querify.Format{null}, nil},
+	}
+
+	for _, test := range tests {
+		got, err := test.variable.Variable(record)
+		if err != nil {
+			t.Fatal(test.variable, err)
+		}
+
+		if got != test.want {
+			t.Fatalf("%v: %#v", test.variable, got)
+		}
+	}
+
+	matches := querify.RegexpMatches{Expression: lit("a1b22"), Pattern: lit("([a-z])([0-9]+)"), Flags: lit("g")}
+
+	got, err := matches.Variable(record)
+	if err != nil || !reflect.DeepEqual(got, []interface{}{[]interface{}{"a", "1"}, []interface{}{"b", "22"}}) {
+		t.Fatal(got, err)
+	}
+
+	got, err = querify.RegexpMatches{Expression: lit("a1b22"), Pattern: lit("[0-9]+")}.Variable(record)
+	if err != nil || !reflect.DeepEqual(got, []interface{}{"1"}) {
+		t.Fatal(got, err)
+	}
+
+	for _, v := range []querify.Variable{
+		querify.Substring{Expression: text, From: lit(1), For: lit(-1)},
+		querify.SplitPart{text, lit(","), lit(0)},
+		querify.Left{text, lit("x")},
+		querify.Left{text, lit(1.5)},
+		querify.Lower{lit(123)},
+		querify.Length{lit(1.5)},
+		querify.Length{number},
+		querify.Replace{text, lit(true), lit("x")},
+		querify.RegexpReplace{Expression: text, Pattern: lit("a"), Replacement: lit("b"), Flags: lit("z")},
+		querify.Format{lit("%s %s"), lit("a")},
+		querify.Format{lit("%d"), lit(1)},
+		querify.Format{lit("%I"), null},
+	} {
+		if _, err := v.Variable(record); err == nil {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	stmt, err := querify.Parse(`SELECT upper(left(name, 3)) AS short, position('o' IN name) AS pos, `+
+		`substring(name FROM 2 FOR 3) AS sub, trim(LEADING 'H' FROM name) AS trimmed, `+
+		`format('%s-%s', id, lpad(id::text, 2, '0')) AS tag `+
+		`FROM hobbies WHERE length(name) > 6 `+
+		`AND split_part(regexp_replace(name, 'ball$', '-ball'), '-', 2) = 'ball'`, tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"short": "FOO", "pos": 2.0, "sub": "oot", "trimmed": "Football", "tag": "1-01"},
+		{"short": "BAS", "pos": 0.0, "sub": "ask", "trimmed": "Basketball", "tag": "2-02"},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, tables())
+}
@@ -0,0 +1,931 @@
+package querify
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Over evaluates a window function or an aggregate over the rows of a window.
+type Over struct {
+	Function    Variable
+	PartitionBy []Variable
+	OrderBy     []OrderBy
+	Frame       *Frame
+}
+
+func (o Over) Variable(record SelectedRecord) (Value, error) {
+	return nil, fmt.Errorf("querify: window functions cannot be nested in expressions")
+}
+
+func (o Over) Select(table SelectedTable) (string, []Value, error) {
+	name := "?column?"
+
+	if s, err := render(o.Function); err == nil && strings.Contains(s, "(") {
+		name = s[:strings.IndexByte(s, '(')]
+	}
+
+	partitions, err := o.partitions(table)
+	if err != nil {
+		return "", nil, err
+	}
+
+	out := make([]Value, len(table.Source.Data))
+
+	for _, indexes := range partitions {
+		rows, err := o.sort(table, indexes)
+		if err != nil {
+			return "", nil, err
+		}
+
+		records, err := o.records(table, rows)
+		if err != nil {
+			return "", nil, err
+		}
+
+		values, err := o.evaluate(table, records)
+		if err != nil {
+			return "", nil, err
+		}
+
+		for i, value := range values {
+			out[rows[i]] = value
+		}
+	}
+
+	return name, out, nil
+}
+
+func (o Over) SQL() (string, error) {
+	function, err := render(o.Function)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+
+	if len(o.PartitionBy) > 0 {
+		list, err := renderVariables(o.PartitionBy)
+		if err != nil {
+			return "", err
+		}
+
+		parts = append(parts, "PARTITION BY "+list)
+	}
+
+	if len(o.OrderBy) > 0 {
+		orders := make([]interface{}, len(o.OrderBy))
+
+		for i, order := range o.OrderBy {
+			orders[i] = order
+		}
+
+		list, err := renderList(orders)
+		if err != nil {
+			return "", err
+		}
+
+		parts = append(parts, "ORDER BY "+list)
+	}
+
+	if o.Frame != nil {
+		frame, err := o.Frame.SQL()
+		if err != nil {
+			return "", err
+		}
+
+		parts = append(parts, frame)
+	}
+
+	return function + " OVER (" + strings.Join(parts, " ") + ")", nil
+}
+
+// partitions returns the indexes of the rows of each partition in the order of their first row.
+func (o Over) partitions(table SelectedTable) ([][]int, error) {
+	var partitions [][]int
+
+	index := map[string]int{}
+
+	for i := range table.Source.Data {
+		values := make([]Value, len(o.PartitionBy))
+
+		for j, p := range o.PartitionBy {
+			value, err := p.Variable(table.Record(i))
+			if err != nil {
+				return nil, err
+			}
+
+			if values[j], err = normalize(value); err != nil {
+				return nil, err
+			}
+
+			if t, ok := values[j].(time.Time); ok {
+				values[j] = t.UTC()
+			}
+		}
+
+		b, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+
+		n, ok := index[string(b)]
+		if !ok {
+			n = len(partitions)
+			index[string(b)] = n
+			partitions = append(partitions, nil)
+		}
+
+		partitions[n] = append(partitions[n], i)
+	}
+
+	return partitions, nil
+}
+
+func (o Over) sort(table SelectedTable, rows []int) ([]int, error) {
+	var err error
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+
+		var c int
+
+		c, err = o.compare(table.Record(rows[i]), table.Record(rows[j]))
+
+		return c < 0
+	})
+
+	return rows, err
+}
+
+func (o Over) compare(i, j SelectedRecord) (int, error) {
+	for _, order := range o.OrderBy {
+		c, err := order.OrderBy(i, j)
+		if err != nil || c != 0 {
+			return c, err
+		}
+	}
+
+	return 0, nil
+}
+
+// records returns the window records of the sorted rows of a partition.
+func (o Over) records(table SelectedTable, rows []int) ([]WindowRecord, error) {
+	partition := make([]SelectedRecord, len(rows))
+
+	for i, r := range rows {
+		partition[i] = table.Record(r)
+	}
+
+	records := make([]WindowRecord, len(rows))
+
+	for i := range records {
+		records[i] = WindowRecord{Rows: partition, Row: i, PeerStart: i}
+
+		if i == 0 {
+			continue
+		}
+
+		c, err := o.compare(partition[i-1], partition[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if c == 0 {
+			records[i].PeerStart = records[i-1].PeerStart
+			records[i].PeerGroup = records[i-1].PeerGroup
+		} else {
+			records[i].PeerGroup = records[i-1].PeerGroup + 1
+		}
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		records[i].PeerEnd = len(records)
+
+		if i+1 < len(records) && records[i+1].PeerStart == records[i].PeerStart {
+			records[i].PeerEnd = records[i+1].PeerEnd
+		} else if i+1 < len(records) {
+			records[i].PeerEnd = i + 1
+		}
+	}
+
+	keys, err := o.keys(partition)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range records {
+		start, end, err := o.frame(records[i], keys)
+		if err != nil {
+			return nil, err
+		}
+
+		if end < start {
+			end = start
+		}
+
+		records[i].FrameStart, records[i].FrameEnd = start, end
+	}
+
+	return records, nil
+}
+
+func (o Over) frame(record WindowRecord, keys *rangeKeys) (int, int, error) {
+	if o.Frame == nil {
+		if len(o.OrderBy) == 0 {
+			return 0, len(record.Rows), nil
+		}
+
+		return 0, record.PeerEnd, nil
+	}
+
+	start, err := o.bound(o.Frame.Start, record, keys, true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := o.bound(o.Frame.End, record, keys, false)
+
+	return start, end, err
+}
+
+// bound returns the index of the first row of the frame for the start, and behind the last row for the end.
+func (o Over) bound(bound Bound, record WindowRecord, keys *rangeKeys, start bool) (int, error) {
+	n := len(record.Rows)
+
+	switch {
+	case bound.Unbounded && bound.Following:
+		return n, nil
+	case bound.Unbounded:
+		return 0, nil
+	case bound.Offset == nil && o.Frame.Range && start:
+		return record.PeerStart, nil
+	case bound.Offset == nil && o.Frame.Range:
+		return record.PeerEnd, nil
+	case bound.Offset == nil && start:
+		return record.Row, nil
+	case bound.Offset == nil:
+		return record.Row + 1, nil
+	}
+
+	offset, err := bound.Offset.Variable(record.Rows[record.Row])
+	if err != nil {
+		return 0, err
+	}
+
+	if offset == nil {
+		return 0, fmt.Errorf("querify: frame offset must not be NULL")
+	}
+
+	if o.Frame.Range {
+		return keys.bound(offset, bound.Following, record, start)
+	}
+
+	rows, err := windowInt("frame offset", offset)
+	if err != nil {
+		return 0, err
+	}
+
+	if rows < 0 {
+		return 0, fmt.Errorf("querify: frame offset must not be negative")
+	}
+
+	position := record.Row - rows
+	if bound.Following {
+		position = record.Row + rows
+	}
+
+	if !start {
+		position++
+	}
+
+	return clampInt(position, 0, n), nil
+}
+
+// rangeKeys are the values of the OrderBy expression of a partition for RANGE offsets.
+type rangeKeys struct {
+	values    []Value
+	low, high int
+	desc      bool
+}
+
+func (o Over) keys(partition []SelectedRecord) (*rangeKeys, error) {
+	if o.Frame == nil || !o.Frame.Range || o.Frame.Start.Offset == nil && o.Frame.End.Offset == nil {
+		return nil, nil
+	}
+
+	var (
+		expression Variable
+		keys       = &rangeKeys{values: make([]Value, len(partition)), low: len(partition)}
+	)
+
+	if len(o.OrderBy) == 1 {
+		switch order := o.OrderBy[0].(type) {
+		case Asc:
+			expression = order.Expression
+		case Desc:
+			expression, keys.desc = order.Expression, true
+		}
+	}
+
+	if expression == nil {
+		return nil, fmt.Errorf("querify: RANGE with offset requires exactly one ORDER BY expression")
+	}
+
+	for i, row := range partition {
+		value, err := expression.Variable(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if value != nil && i < keys.low {
+			keys.low = i
+		}
+
+		if value != nil {
+			keys.high = i + 1
+		}
+
+		keys.values[i] = value
+	}
+
+	return keys, nil
+}
+
+// bound adds the offset to the value of the current row and searches the first or last row within that value.
+func (k *rangeKeys) bound(offset Value, following bool, record WindowRecord, start bool) (int, error) {
+	if f, ok := toFloat(offset); ok && f < 0 {
+		return 0, fmt.Errorf("querify: frame offset must not be negative")
+	}
+
+	current := k.values[record.Row]
+	if current == nil {
+		if start {
+			return record.PeerStart, nil
+		}
+
+		return record.PeerEnd, nil
+	}
+
+	op := "-"
+	if following != k.desc {
+		op = "+"
+	}
+
+	target, err := arithmetic(Literal{Value: current}, Literal{Value: offset}, op, SelectedRecord{})
+	if err != nil {
+		return 0, err
+	}
+
+	search := func(test func(c int) bool) int {
+		return k.low + sort.Search(k.high-k.low, func(i int) bool {
+			if err != nil {
+				return true
+			}
+
+			var c int
+
+			c, err = compare(k.values[k.low+i], target)
+			if k.desc {
+				c = -c
+			}
+
+			return test(c)
+		})
+	}
+
+	if start {
+		out := search(func(c int) bool { return c >= 0 })
+		if out == k.high {
+			out = len(k.values)
+		}
+
+		return out, err
+	}
+
+	out := search(func(c int) bool { return c > 0 })
+	if out == k.low {
+		out = 0
+	}
+
+	return out, err
+}
+
+// evaluate calls the window function for each row. Sum, Avg and counts use running totals.
+func (o Over) evaluate(table SelectedTable, records []WindowRecord) ([]Value, error) {
+	out := make([]Value, len(records))
+
+	if w, ok := o.Function.(Window); ok {
+		for i, record := range records {
+			value, err := w.Window(record)
+			if err != nil {
+				return nil, err
+			}
+
+			out[i] = value
+		}
+
+		return out, nil
+	}
+
+	if len(records) == 0 {
+		return out, nil
+	}
+
+	if r, ok := runningAggregate(o.Function); ok {
+		return r.evaluate(o.rows(table, records[0].Rows), records)
+	}
+
+	for i, record := range records {
+		if i > 0 && record.FrameStart == records[i-1].FrameStart && record.FrameEnd == records[i-1].FrameEnd {
+			out[i] = out[i-1]
+
+			continue
+		}
+
+		value, err := o.Function.Variable(o.rows(table, record.Rows[record.FrameStart:record.FrameEnd]))
+		if err != nil {
+			return nil, err
+		}
+
+		out[i] = value
+	}
+
+	return out, nil
+}
+
+// rows returns a record, whose group are the rows. If the table is grouped, the rows keep their groups.
+func (o Over) rows(table SelectedTable, rows []SelectedRecord) SelectedRecord {
+	frame := Table{Columns: table.Source.Columns, Data: make([][]Value, len(rows))}
+
+	grouped := len(table.Grouped) == len(table.Source.Data)
+
+	for _, g := range table.Grouped {
+		grouped = grouped && g.grouping != nil
+	}
+
+	if grouped {
+		frame.groups = make([]Table, len(rows))
+	}
+
+	for i, row := range rows {
+		frame.Data[i] = row.Source.Values
+
+		if grouped {
+			frame.groups[i] = row.Grouped
+		}
+	}
+
+	return SelectedRecord{Grouped: frame, Outer: table.outer, now: table.now}
+}
+
+// running is an aggregate, that can be computed from running totals.
+type running struct {
+	name       string
+	expression Select
+	filter     Condition
+	sum        bool
+	result     func(total *summation, n int) (Value, error)
+}
+
+func runningAggregate(function Variable) (running, bool) {
+	switch f := function.(type) {
+	case Sum:
+		return running{name: "sum", expression: f.Expression, filter: f.Filter, sum: true, result: runningSum}, !f.Distinct
+	case Avg:
+		return running{name: "avg", expression: f.Expression, filter: f.Filter, sum: true, result: runningAvg}, !f.Distinct
+	case CountOf:
+		return running{expression: f.Expression, filter: f.Filter, result: runningCount}, !f.Distinct
+	case Count:
+		return running{expression: Ident(f), result: runningCount}, true
+	case CountAll:
+		return running{filter: f.Filter, result: runningCount}, true
+	}
+
+	return running{}, false
+}
+
+func runningSum(total *summation, n int) (Value, error) {
+	if n == 0 {
+		return nil, nil
+	}
+
+	return total.result()
+}
+
+func runningAvg(total *summation, n int) (Value, error) {
+	if n == 0 {
+		return nil, nil
+	}
+
+	value, err := total.result()
+	if err != nil {
+		return nil, err
+	}
+
+	return mean(value, n), nil
+}
+
+func runningCount(total *summation, n int) (Value, error) {
+	return n, nil
+}
+
+// evaluate restarts the total, when the start of the frame moves. Counts subtract the rows leaving the frame instead.
+func (r running) evaluate(partition SelectedRecord, records []WindowRecord) ([]Value, error) {
+	values := make([]Value, len(partition.Grouped.Data))
+
+	if r.expression != nil {
+		_, selected, err := r.expression.Select(partition.rows())
+		if err != nil {
+			return nil, err
+		}
+
+		copy(values, selected)
+	} else {
+		for i := range values {
+			values[i] = true
+		}
+	}
+
+	if r.filter != nil {
+		rows := partition.rows()
+
+		for i := range values {
+			ok, err := r.filter.Condition(rows.Record(i).grouped())
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				values[i] = nil
+			}
+		}
+	}
+
+	var (
+		out        = make([]Value, len(records))
+		total      = summation{name: r.name}
+		n          int
+		start, end int
+	)
+
+	for i, record := range records {
+		from, to := record.FrameStart, record.FrameEnd
+
+		switch {
+		case from < start || to < end || from > end || from > start && r.sum:
+			total, n, start, end = summation{name: r.name}, 0, from, from
+		case from > start:
+			for ; start < from; start++ {
+				if values[start] != nil {
+					n--
+				}
+			}
+		}
+
+		for ; end < to; end++ {
+			if values[end] == nil {
+				continue
+			}
+
+			n++
+
+			if r.sum {
+				if err := total.add(values[end]); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		value, err := r.result(&total, n)
+		if err != nil {
+			return nil, err
+		}
+
+		out[i] = value
+	}
+
+	return out, nil
+}
+
+// Frame limits the rows of a window like 'ROWS BETWEEN 1 PRECEDING AND CURRENT ROW'.
+type Frame struct {
+	Range bool
+	Start Bound
+	End   Bound
+}
+
+func (f Frame) SQL() (string, error) {
+	start, err := f.Start.SQL()
+	if err != nil {
+		return "", err
+	}
+
+	end, err := f.End.SQL()
+	if err != nil {
+		return "", err
+	}
+
+	if f.Range {
+		return "RANGE BETWEEN " + start + " AND " + end, nil
+	}
+
+	return "ROWS BETWEEN " + start + " AND " + end, nil
+}
+
+// Bound is the current row by default, or the row at Offset before or after it.
+type Bound struct {
+	Unbounded bool
+	Offset    Variable
+	Following bool
+}
+
+func (b Bound) SQL() (string, error) {
+	direction := " PRECEDING"
+	if b.Following {
+		direction = " FOLLOWING"
+	}
+
+	switch {
+	case b.Unbounded:
+		return "UNBOUNDED" + direction, nil
+	case b.Offset == nil:
+		return "CURRENT ROW", nil
+	}
+
+	offset, err := render(b.Offset)
+	if err != nil {
+		return "", err
+	}
+
+	return offset + direction, nil
+}
+
+// WindowRecord is the current row of a window. The frame is Rows[FrameStart:FrameEnd].
+type WindowRecord struct {
+	Rows       []SelectedRecord
+	Row        int
+	FrameStart int
+	FrameEnd   int
+	PeerStart  int
+	PeerEnd    int
+	PeerGroup  int
+}
+
+type RowNumber struct{}
+
+func (RowNumber) Window(record WindowRecord) (Value, error) {
+	return int64(record.Row + 1), nil
+}
+
+func (RowNumber) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("row_number")
+}
+
+func (RowNumber) SQL() (string, error) {
+	return "row_number()", nil
+}
+
+// Rank numbers the rows with gaps, peers share a rank.
+type Rank struct{}
+
+func (Rank) Window(record WindowRecord) (Value, error) {
+	return int64(record.PeerStart + 1), nil
+}
+
+func (Rank) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("rank")
+}
+
+func (Rank) SQL() (string, error) {
+	return "rank()", nil
+}
+
+// DenseRank numbers the rows without gaps, peers share a rank.
+type DenseRank struct{}
+
+func (DenseRank) Window(record WindowRecord) (Value, error) {
+	return int64(record.PeerGroup + 1), nil
+}
+
+func (DenseRank) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("dense_rank")
+}
+
+func (DenseRank) SQL() (string, error) {
+	return "dense_rank()", nil
+}
+
+// Ntile divides the partition into Buckets of equal size. The first buckets get the remaining rows.
+type Ntile struct {
+	Buckets Variable
+}
+
+func (n Ntile) Window(record WindowRecord) (Value, error) {
+	value, err := n.Buckets.Variable(record.Rows[record.Row])
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	buckets, err := windowInt("ntile", value)
+	if err != nil {
+		return nil, err
+	}
+
+	if buckets <= 0 {
+		return nil, fmt.Errorf("querify: argument of ntile must be greater than zero")
+	}
+
+	size, rest := len(record.Rows)/buckets, len(record.Rows)%buckets
+
+	if record.Row < rest*(size+1) {
+		return int64(record.Row/(size+1) + 1), nil
+	}
+
+	return int64(rest + (record.Row-rest*(size+1))/size + 1), nil
+}
+
+func (n Ntile) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("ntile")
+}
+
+func (n Ntile) SQL() (string, error) {
+	return renderCall("ntile", n.Buckets)
+}
+
+// Lag returns the value of the row at Offset, default 1, before the current row, or Default outside the partition.
+type Lag struct {
+	Expression Variable
+	Offset     Variable
+	Default    Variable
+}
+
+func (l Lag) Window(record WindowRecord) (Value, error) {
+	return shift(record, l.Expression, l.Offset, l.Default, -1)
+}
+
+func (l Lag) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("lag")
+}
+
+func (l Lag) SQL() (string, error) {
+	return renderCall("lag", optional(l.Expression, l.Offset, l.Default)...)
+}
+
+// Lead returns the value of the row at Offset, default 1, after the current row, or Default outside the partition.
+type Lead struct {
+	Expression Variable
+	Offset     Variable
+	Default    Variable
+}
+
+func (l Lead) Window(record WindowRecord) (Value, error) {
+	return shift(record, l.Expression, l.Offset, l.Default, 1)
+}
+
+func (l Lead) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("lead")
+}
+
+func (l Lead) SQL() (string, error) {
+	return renderCall("lead", optional(l.Expression, l.Offset, l.Default)...)
+}
+
+// FirstValue returns the value of the first row of the frame.
+type FirstValue struct {
+	Expression Variable
+}
+
+func (f FirstValue) Window(record WindowRecord) (Value, error) {
+	return nth(record, f.Expression, 1)
+}
+
+func (f FirstValue) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("first_value")
+}
+
+func (f FirstValue) SQL() (string, error) {
+	return renderCall("first_value", f.Expression)
+}
+
+// LastValue returns the value of the last row of the frame.
+type LastValue struct {
+	Expression Variable
+}
+
+func (l LastValue) Window(record WindowRecord) (Value, error) {
+	return nth(record, l.Expression, record.FrameEnd-record.FrameStart)
+}
+
+func (l LastValue) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("last_value")
+}
+
+func (l LastValue) SQL() (string, error) {
+	return renderCall("last_value", l.Expression)
+}
+
+// NthValue returns the value of the N-th row of the frame, counting from 1.
+type NthValue struct {
+	Expression Variable
+	N          Variable
+}
+
+func (n NthValue) Window(record WindowRecord) (Value, error) {
+	value, err := n.N.Variable(record.Rows[record.Row])
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	i, err := windowInt("nth_value", value)
+	if err != nil {
+		return nil, err
+	}
+
+	if i <= 0 {
+		return nil, fmt.Errorf("querify: argument of nth_value must be greater than zero")
+	}
+
+	return nth(record, n.Expression, i)
+}
+
+func (n NthValue) Variable(record SelectedRecord) (Value, error) {
+	return nil, windowOnly("nth_value")
+}
+
+func (n NthValue) SQL() (string, error) {
+	return renderCall("nth_value", n.Expression, n.N)
+}
+
+func windowOnly(name string) error {
+	return fmt.Errorf("querify: window function %s requires OVER", name)
+}
+
+// windowInt accepts integers and whole floats, since numbers of data loaded with From are float64.
+func windowInt(name string, value Value) (int, error) {
+	if i, ok := toInt(value); ok {
+		return int(i), nil
+	}
+
+	if f, ok := toFloat(value); ok && f == math.Trunc(f) {
+		return int(f), nil
+	}
+
+	return 0, fmt.Errorf("querify: %s expects an integer, got '%v'", name, value)
+}
+
+func clampInt(i, min, max int) int {
+	switch {
+	case i < min:
+		return min
+	case i > max:
+		return max
+	}
+
+	return i
+}
+
+// shift evaluates the expression at offset rows in direction from the current row.
+func shift(record WindowRecord, expression, offset, fallback Variable, direction int) (Value, error) {
+	current := record.Rows[record.Row]
+	rows := 1
+
+	if offset != nil {
+		value, err := offset.Variable(current)
+		if err != nil || value == nil {
+			return nil, err
+		}
+
+		if rows, err = windowInt("offset", value); err != nil {
+			return nil, err
+		}
+	}
+
+	i := record.Row + direction*rows
+
+	if i < 0 || i >= len(record.Rows) {
+		if fallback == nil {
+			return nil, nil
+		}
+
+		return fallback.Variable(current)
+	}
+
+	return expression.Variable(record.Rows[i])
+}
+
+// nth evaluates the expression at the n-th row of the frame, counting from 1.
+func nth(record WindowRecord, expression Variable, n int) (Value, error) {
+	i := record.FrameStart + n - 1
+
+	if n <= 0 || i >= record.FrameEnd {
+		return nil, nil
+	}
+
+	return expression.Variable(record.Rows[i])
+}
@@ -0,0 +1,192 @@
+package querify_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wroge/querify"
+)
+
+func TestWindow(t *testing.T) {
+	table := querify.SelectedTable{Source: querify.Table{Columns: []string{"dept", "salary"}, Data: [][]querify.Value{
+		{"a", 10}, {"a", 20}, {"a", 20}, {"b", 5}, {"a", 30},
+	}}}
+
+	dept, salary := querify.Ident("dept"), querify.Ident("salary")
+	lit := func(v querify.Value) querify.Literal { return querify.Literal{Value: v} }
+	window := func(function querify.Variable, frame *querify.Frame) querify.Over {
+		return querify.Over{
+			Function:    function,
+			PartitionBy: []querify.Variable{dept},
+			OrderBy:     []querify.OrderBy{querify.Asc{Expression: salary}},
+			Frame:       frame,
+		}
+	}
+
+	tests := []struct {
+		over querify.Over
+		want []querify.Value
+	}{
+		{window(querify.RowNumber{}, nil), []querify.Value{int64(1), int64(2), int64(3), int64(1), int64(4)}},
+		{window(querify.Rank{}, nil), []querify.Value{int64(1), int64(2), int64(2), int64(1), int64(4)}},
+		{window(querify.DenseRank{}, nil), []querify.Value{int64(1), int64(2), int64(2), int64(1), int64(3)}},
+		{window(querify.Ntile{Buckets: lit(3)}, nil), []querify.Value{int64(1), int64(1), int64(2), int64(1), int64(3)}},
+		{window(querify.Lag{Expression: salary}, nil), []querify.Value{nil, 10, 20, nil, 20}},
+		{
+			window(querify.Lead{Expression: salary, Offset: lit(2), Default: lit(-1)}, nil),
+			[]querify.Value{20, 30, -1, -1, -1},
+		},
+		{window(querify.FirstValue{Expression: salary}, nil), []querify.Value{10, 10, 10, 5, 10}},
+		{window(querify.LastValue{Expression: salary}, nil), []querify.Value{10, 20, 20, 5, 30}},
+		{window(querify.NthValue{Expression: salary, N: lit(2)}, nil), []querify.Value{nil, 20, 20, nil, 20}},
+		{window(querify.Sum{Expression: salary}, nil), []querify.Value{int64(10), int64(50), int64(50), int64(5), int64(80)}},
+		{window(querify.Sum{Expression: salary}, &querify.Frame{Start: querify.Bound{Offset: lit(1)}}),
+			[]querify.Value{int64(10), int64(30), int64(40), int64(5), int64(50)}},
+		{window(querify.Sum{Expression: salary}, &querify.Frame{Range: true, Start: querify.Bound{Offset: lit(10)}}),
+			[]querify.Value{int64(10), int64(50), int64(50), int64(5), int64(70)}},
+		{window(querify.CountAll{}, &querify.Frame{
+			Start: querify.Bound{Unbounded: true},
+			End:   querify.Bound{Unbounded: true, Following: true},
+		}), []querify.Value{4, 4, 4, 1, 4}},
+		{window(querify.LastValue{Expression: salary}, &querify.Frame{
+			Start: querify.Bound{Offset: lit(1), Following: true},
+			End:   querify.Bound{Offset: lit(1), Following: true},
+		}), []querify.Value{20, 20, 30, nil, nil}},
+		{querify.Over{Function: querify.Max{Expression: salary}}, []querify.Value{30, 30, 30, 30, 30}},
+		{window(querify.Avg{Expression: salary, Filter: querify.Greater{salary, lit(10)}}, nil),
+			[]querify.Value{nil, 20.0, 20.0, nil, 70.0 / 3}},
+		{window(querify.CountOf{Expression: salary}, &querify.Frame{
+			Start: querify.Bound{Offset: lit(1)},
+			End:   querify.Bound{Unbounded: true, Following: true},
+		}), []querify.Value{4, 4, 3, 1, 2}},
+		{window(querify.Min{Expression: salary}, &querify.Frame{Start: querify.Bound{Offset: lit(1)}}),
+			[]querify.Value{10, 10, 20, 5, 20}},
+		{querify.Over{Function: querify.RowNumber{}, OrderBy: []querify.OrderBy{querify.Desc{Expression: salary}}},
+			[]querify.Value{int64(4), int64(2), int64(3), int64(5), int64(1)}},
+	}
+
+	for _, test := range tests {
+		_, got, err := test.over.Select(table)
+		if err != nil {
+			t.Fatal(test.over, err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%v: %#v", test.over, got)
+		}
+	}
+
+	for _, over := range []querify.Over{
+		window(querify.Ntile{Buckets: lit(0)}, nil),
+		window(querify.NthValue{Expression: salary, N: lit(0)}, nil),
+		window(querify.Sum{Expression: salary}, &querify.Frame{Start: querify.Bound{Offset: lit(-1)}}),
+		{Function: querify.Sum{Expression: salary}, Frame: &querify.Frame{Range: true, Start: querify.Bound{Offset: lit(1)}}},
+	} {
+		if _, _, err := over.Select(table); err == nil {
+			t.Fatal(over)
+		}
+	}
+
+	if _, err := (querify.RowNumber{}).Variable(table.Record(0)); err == nil {
+		t.Fatal(err)
+	}
+
+	if _, err := window(querify.Rank{}, nil).Variable(table.Record(0)); err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	data := tables()
+	data["employees"] = querify.From([]map[string]interface{}{
+		{"id": 1, "dept": "a", "salary": 10},
+		{"id": 2, "dept": "a", "salary": 20},
+		{"id": 3, "dept": "b", "salary": 5},
+		{"id": 4, "dept": "a", "salary": 20},
+		{"id": 5, "dept": "b", "salary": 15},
+	})
+
+	stmt, err := querify.Parse(`SELECT id, row_number() OVER (PARTITION BY dept ORDER BY salary DESC, id) AS n, `+
+		`rank() OVER (ORDER BY salary DESC) AS rank, sum(salary) OVER (PARTITION BY dept) AS total, `+
+		`sum(salary) OVER (ORDER BY id ROWS BETWEEN 1 PRECEDING AND 1 FOLLOWING) AS moving, `+
+		`sum(salary) OVER (ORDER BY salary RANGE BETWEEN 5 PRECEDING AND CURRENT ROW) AS ranged, `+
+		`lag(salary, 1, 0) OVER (ORDER BY id) AS previous FROM employees ORDER BY id`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"id": 1.0, "n": 3.0, "rank": 4.0, "total": 50.0, "moving": 30.0, "ranged": 15.0, "previous": 0.0},
+		{"id": 2.0, "n": 1.0, "rank": 1.0, "total": 50.0, "moving": 35.0, "ranged": 55.0, "previous": 10.0},
+		{"id": 3.0, "n": 2.0, "rank": 5.0, "total": 20.0, "moving": 45.0, "ranged": 5.0, "previous": 20.0},
+		{"id": 4.0, "n": 2.0, "rank": 1.0, "total": 50.0, "moving": 40.0, "ranged": 55.0, "previous": 5.0},
+		{"id": 5.0, "n": 1.0, "rank": 3.0, "total": 20.0, "moving": 35.0, "ranged": 25.0, "previous": 20.0},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	roundTrip(t, stmt, data)
+
+	stmt, err = querify.Parse(`SELECT dept, sum(salary) AS total, rank() OVER (ORDER BY sum(salary) DESC) AS rank `+
+		`FROM employees GROUP BY dept ORDER BY dept`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out = nil
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []map[string]interface{}{
+		{"dept": "a", "total": 50.0, "rank": 1.0},
+		{"dept": "b", "total": 20.0, "rank": 2.0},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	stmt, err = querify.Parse(`SELECT dept, sum(sum(salary)) OVER () AS total, count(*) OVER () AS depts, `+
+		`max(count(*)) OVER (ORDER BY dept) AS most, array_agg(min(id)) OVER (ORDER BY dept) AS firsts `+
+		`FROM employees GROUP BY dept ORDER BY dept`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out = nil
+
+	if err = stmt.Scan(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []map[string]interface{}{
+		{"dept": "a", "total": 70.0, "depts": 2.0, "most": 3.0, "firsts": []interface{}{1.0}},
+		{"dept": "b", "total": 70.0, "depts": 2.0, "most": 3.0, "firsts": []interface{}{1.0, 3.0}},
+	}
+
+	if !reflect.DeepEqual(out, want) {
+		t.Fatal(out)
+	}
+
+	for _, query := range []string{
+		`SELECT row_number() FROM employees`,
+		`SELECT lower(dept) OVER () FROM employees`,
+		`SELECT rank(id) OVER () FROM employees`,
+		`SELECT sum(salary) OVER (ROWS 1) FROM employees`,
+		`SELECT sum(salary) OVER (ROWS BETWEEN UNBOUNDED AND CURRENT ROW) FROM employees`,
+	} {
+		if _, err = querify.Parse(query, data); err == nil {
+			t.Fatal(query)
+		}
+	}
+}